@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Matches the path:line: or path:line:column: prefix grep, rg, and compilers write before a match
+var LOCATION_PATTERN = regexp.MustCompile(`^([^:\s][^:]*):(\d+):(?:(\d+):)?`)
+
+// Placeholders an action template can use, and the environment-variable each is replaced with
+var ACTION_PLACEHOLDERS = [][]string{
+	{"{file}", "RL_FILE"},
+	{"{line}", "RL_LINE"},
+	{"{column}", "RL_COLUMN"},
+	{"{text}", "RL_TEXT"},
+}
+
+// Where an output line points to, like a file and line-number
+type LineLocation struct {
+	file   string
+	line   string
+	column string
+	text   string
+}
+
+// Find the file and line an output line refers to. Lines without a path:line: prefix
+// are taken to be a file-name, so actions also work on the output of ls or fd
+func ParseLocation(text string) LineLocation {
+	match := LOCATION_PATTERN.FindStringSubmatch(text)
+
+	if match == nil {
+		return LineLocation{text, "1", "1", text}
+	}
+
+	column := match[3]
+	if column == "" {
+		column = "1"
+	}
+
+	return LineLocation{match[1], match[2], column, text}
+}
+
+// The environment-variables an action reads its placeholders from
+func (loc LineLocation) EnvVars() []string {
+	return []string{
+		"RL_FILE=" + loc.file,
+		"RL_LINE=" + loc.line,
+		"RL_COLUMN=" + loc.column,
+		"RL_TEXT=" + loc.text,
+	}
+}
+
+// Find the placeholder at the start of some text; returns its environment-variable and length
+func placeholderAt(text string) (string, int, bool) {
+	for _, pair := range ACTION_PLACEHOLDERS {
+		if strings.HasPrefix(text, pair[0]) {
+			return pair[1], len(pair[0]), true
+		}
+	}
+
+	return "", 0, false
+}
+
+// Replace each placeholder in an action template with a quoted environment-variable, so
+// the line's text is never evaluated as shell. Placeholders may already be quoted, like '{file}'
+// or "{line}"; the variable is written to expand within those quotes, not as literal text
+func ActionScript(template string) string {
+	var script strings.Builder
+
+	quote := byte(0) // the quote-character the template is inside at this point, if any
+	escaped := false // was the last character an unquoted or double-quoted backslash?
+
+	for idx := 0; idx < len(template); {
+		if name, size, ok := placeholderAt(template[idx:]); ok && !escaped {
+			switch quote {
+			case '\'':
+				// nothing expands in single-quotes, so close them around the variable
+				script.WriteString(`'"$` + name + `"'`)
+			case '"':
+				// braced, so text following the placeholder isn't read as part of the name
+				script.WriteString("${" + name + "}")
+			default:
+				script.WriteString(`"$` + name + `"`)
+			}
+
+			idx += size
+			continue
+		}
+
+		char := template[idx]
+
+		if escaped {
+			escaped = false
+		} else if char == '\\' && quote != '\'' {
+			escaped = true
+		} else if quote == 0 && (char == '\'' || char == '"') {
+			quote = char
+		} else if char == quote {
+			quote = 0
+		}
+
+		script.WriteByte(char)
+		idx++
+	}
+
+	return script.String()
+}
+
+// Check actions are bound to a single key view-mode doesn't already use
+func CheckActions(actions map[string]string, keys KeyBindings) error {
+	for key, template := range actions {
+		if utf8.RuneCountInString(key) != 1 {
+			return fmt.Errorf("actions: '%v' must be a single character", key)
+		}
+
+		chord, err := ParseChord(key)
+		if err != nil {
+			return fmt.Errorf("actions: %v", err)
+		}
+
+		if _, bound := keys[ViewMode][chord]; bound || CheckChordFor(ViewMode, chord) != nil {
+			return fmt.Errorf("actions: '%v' is already used in view mode", key)
+		}
+
+		if strings.TrimSpace(template) == "" {
+			return fmt.Errorf("actions: '%v' has no command", key)
+		}
+	}
+
+	return nil
+}
+
+// Run the action bound to a key against the line under the cursor. rl's screen is handed
+// to the action, so editors and pagers work, and view-mode returns when it exits
+func (tui *TUI) RunAction(key string, template string) {
+	selection := &tui.stdoutViewer.selection
+
+	if selection.Len() == 0 {
+		return
+	}
+
+	loc := ParseLocation(selection.lines.Plain(selection.cursor))
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		tui.ShowError(fmt.Errorf("action '%v' failed: %v", key, err))
+		return
+	}
+	defer tty.Close()
+
+	// actions always run in sh, whichever shell runs <cmd>
+	cmd := exec.Command("sh", "-c", ActionScript(template))
+	cmd.Stdin = tty
+	cmd.Stdout = tty
+	cmd.Stderr = tty
+	cmd.Env = append(append(os.Environ(), ENVAR_NAME_RL_INPUT+"="+tui.state.lineBuffer.content), loc.EnvVars()...)
+
+	var runErr error
+	tui.app.tview.Suspend(func() {
+		runErr = cmd.Run()
+	})
+
+	if runErr != nil {
+		tui.ShowError(fmt.Errorf("action '%v' failed: %v", key, runErr))
+	} else {
+		tui.ShowMessage(tui.keys.HelpBar(ViewMode))
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestActionScript(t *testing.T) {
+	cases := []struct {
+		template string
+		expected string
+	}{
+		{`$EDITOR +{line} {file}`, `$EDITOR +"$RL_LINE" "$RL_FILE"`},
+		{`less {file}`, `less "$RL_FILE"`},
+		{`echo {text} {column}`, `echo "$RL_TEXT" "$RL_COLUMN"`},
+		{`code -g {file}:{line}:{column}`, `code -g "$RL_FILE":"$RL_LINE":"$RL_COLUMN"`},
+		{`vim '{file}'`, `vim ''"$RL_FILE"''`},
+		{`vim "{file}"`, `vim "${RL_FILE}"`},
+		{`vim "+{line}x" '{file}.bak'`, `vim "+${RL_LINE}x" ''"$RL_FILE"'.bak'`},
+		{`echo "it's {text}"`, `echo "it's ${RL_TEXT}"`},
+		{`echo \{file} {file}`, `echo \{file} "$RL_FILE"`},
+		{`echo "\"{file}"`, `echo "\"${RL_FILE}"`},
+		{`echo '\' {file}`, `echo '\' "$RL_FILE"`},
+		{`echo {other}`, `echo {other}`},
+	}
+
+	for _, testCase := range cases {
+		if actual := ActionScript(testCase.template); actual != testCase.expected {
+			t.Errorf("%q: expected %q, got %q", testCase.template, testCase.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The escape-sequence asking the terminal to copy text to the clipboard. Terminals read it even over
+// SSH, and tmux passes it on with set-clipboard on
+func OSC52Sequence(text string) string {
+	return "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\x07"
+}
+
+// Write an OSC 52 sequence to the terminal
+func WriteOSC52(sequence string) error {
+	tty, err := OpenTTY()
+	if err != nil {
+		return fmt.Errorf("failed to open the terminal: %v", err)
+	}
+	defer tty.Close()
+
+	_, err = tty.WriteString(sequence)
+	return err
+}
+
+// Run clipboard_command with the text on its standard-input. Commands like xclip and wl-copy leave a
+// child running to serve the clipboard, so the command reads and writes files rather than pipes that child
+// would hold open. Its process-group is killed if it runs past CLIPBOARD_TIMEOUT_MS
+func RunClipboardCommand(command string, text string) error {
+	stdin, err := ioutil.TempFile("", "rl-clipboard")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(stdin.Name())
+	defer stdin.Close()
+
+	stderr, err := ioutil.TempFile("", "rl-clipboard")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(stderr.Name())
+	defer stderr.Close()
+
+	if _, err := stdin.WriteString(text); err != nil {
+		return err
+	}
+	if _, err := stdin.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = stdin
+	cmd.Stderr = stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-exited:
+		if err != nil {
+			output, _ := ioutil.ReadFile(stderr.Name())
+			return fmt.Errorf("clipboard_command failed: %v %v", err, strings.TrimSpace(string(output)))
+		}
+
+		return nil
+	case <-time.After(CLIPBOARD_TIMEOUT_MS * time.Millisecond):
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-exited
+
+		return fmt.Errorf("clipboard_command timed out after %vms", CLIPBOARD_TIMEOUT_MS)
+	}
+}
+
+// Copy text to the clipboard with OSC 52, falling back to clipboard_command when OSC 52 can't be used. The
+// command runs off the UI's goroutine, so a slow one can't freeze rl; done is called on the UI's goroutine
+func (tui *TUI) CopyToClipboard(text string, done func(err error)) {
+	command := tui.cfg.Config.ClipboardCommand
+	sequence := OSC52Sequence(text)
+
+	// terminals ignore larger sequences, so don't claim these were copied
+	if len(sequence) <= OSC52_MAX_BYTES {
+		err := WriteOSC52(sequence)
+
+		if err == nil || command == "" {
+			done(err)
+			return
+		}
+	} else if command == "" {
+		done(fmt.Errorf("%v bytes is too much to copy with OSC 52; set clipboard_command to copy it", len(text)))
+		return
+	}
+
+	go func() {
+		err := RunClipboardCommand(command, text)
+
+		tui.app.tview.QueueUpdateDraw(func() {
+			done(err)
+		})
+	}()
+}
+
+// Copy lines to the clipboard, and say how many were copied once they have been
+func (tui *TUI) CopyLines(lines []string) error {
+	if len(lines) == 0 {
+		return errors.New("there's no output to copy")
+	}
+
+	message := fmt.Sprintf("copied %v lines", len(lines))
+	if len(lines) == 1 {
+		message = "copied 1 line"
+	}
+
+	tui.CopyToClipboard(strings.Join(lines, "\n"), func(err error) {
+		if err != nil {
+			tui.ShowError(err)
+		} else {
+			tui.ShowTransient(message)
+		}
+	})
+
+	return nil
+}
+
+// The lines y copies in view mode; the marked lines, or the line under the cursor. Y copies the whole output
+func (tui *TUI) YankLines(all bool) []string {
+	if all {
+		return tui.stdoutViewer.selection.PlainLines()
+	}
+
+	return tui.stdoutViewer.selection.Chosen()
+}
+
+// Copy lines in view mode, confirming in the help-bar for a moment
+func (tui *TUI) Yank(all bool) {
+	if err := tui.CopyLines(tui.YankLines(all)); err != nil {
+		tui.ShowError(err)
+	}
+}
+
+// :yank copies the marked lines, or the line under the cursor; :yank all copies the whole output
+func RunYankCommand(tui *TUI, args string) error {
+	if args != "" && args != "all" {
+		return fmt.Errorf("expected 'yank' or 'yank all', but got 'yank %v'", args)
+	}
+
+	lines := tui.YankLines(args == "all")
+	if len(lines) == 0 {
+		return errors.New("there's no output to copy")
+	}
+
+	// return to view-mode first, so the confirmation isn't replaced by its help
+	tui.SetMode(ViewMode)
+	return tui.CopyLines(lines)
+}
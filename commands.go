@@ -0,0 +1,321 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// A command run by RL itself from command-mode, rather than in the user's shell
+type RLCommand struct {
+	name  string                            // the name typed to run the command
+	usage string                            // the arguments the command expects
+	run   func(tui *TUI, args string) error // run the command with the text following its name
+}
+
+// List the commands available in command-mode
+func RLCommands() []RLCommand {
+	return []RLCommand{
+		{"env", "NAME=VALUE", RunEnvCommand},
+		{"find", "TEXT", RunFindCommand},
+		{"highlight", "literal|regex|off", RunHighlightCommand},
+		{"history", "", RunHistoryCommand},
+		{"quit", "", RunQuitCommand},
+		{"save", "NAME", RunSaveCommand},
+		{"set", "template TEMPLATE", RunSetCommand},
+		{"wrap", "on|off", RunWrapCommand},
+		{"write", "FILE", RunWriteCommand},
+		{"yank", "[all]", RunYankCommand},
+	}
+}
+
+// Split command-mode text into a command-name and its arguments. A leading ':' is optional
+func ParseInternalCommand(text string) (string, string) {
+	text = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), ":"))
+	parts := strings.SplitN(text, " ", 2)
+
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+
+	return parts[0], strings.TrimSpace(parts[1])
+}
+
+// Find a command by its name
+func FindInternalCommand(name string) (RLCommand, bool) {
+	for _, command := range RLCommands() {
+		if command.name == name {
+			return command, true
+		}
+	}
+
+	return RLCommand{}, false
+}
+
+// Find the names of commands starting with a prefix
+func CompleteCommandName(prefix string) []string {
+	names := []string{}
+
+	for _, command := range RLCommands() {
+		if strings.HasPrefix(command.name, prefix) {
+			names = append(names, command.name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Show a message in the help-bar
+func (tui *TUI) ShowMessage(message string) {
+	tui.helpBar.tview.SetText(message)
+}
+
+// Show a message in the help-bar for a moment; then what it showed before returns, unless
+// something else was shown meanwhile
+func (tui *TUI) ShowTransient(message string) {
+	previous := tui.HelpText()
+	tui.ShowMessage(message)
+
+	time.AfterFunc(TRANSIENT_MESSAGE_MS*time.Millisecond, func() {
+		tui.app.tview.QueueUpdateDraw(func() {
+			if tui.HelpText() == message {
+				tui.ShowMessage(previous)
+			}
+		})
+	})
+}
+
+// The text shown in the help-bar. tview adds a newline once the text is drawn, which is dropped
+func (tui *TUI) HelpText() string {
+	return strings.TrimSuffix(tui.helpBar.tview.GetText(false), "\n")
+}
+
+// Show an error in the help-bar
+func (tui *TUI) ShowError(err error) {
+	tui.helpBar.tview.SetText("[red]" + tview.Escape(err.Error()) + "[-:-:-]")
+}
+
+// Parse and run command-mode text. On success rl returns to view-mode; on failure
+// the error is shown and the command is left in place to be corrected
+func (tui *TUI) RunInternalCommand(text string) {
+	name, args := ParseInternalCommand(text)
+
+	if name == "" {
+		tui.SetMode(ViewMode)
+		return
+	}
+
+	command, ok := FindInternalCommand(name)
+	if !ok {
+		tui.ShowError(fmt.Errorf("unknown command '%v'. Commands are: %v", name, strings.Join(CompleteCommandName(""), ", ")))
+		return
+	}
+
+	if err := command.run(tui, args); err != nil {
+		tui.ShowError(fmt.Errorf("%v: %v", name, err))
+		return
+	}
+}
+
+// Complete the command-name being typed. If several commands match, complete as much as they
+// share and list them in the help-bar
+func (tui *TUI) CompleteInternalCommand() {
+	text := tui.commandInput.tview.GetText()
+	name, args := ParseInternalCommand(text)
+
+	// only the command-name is completed
+	if args != "" || strings.HasSuffix(text, " ") {
+		return
+	}
+
+	names := CompleteCommandName(name)
+
+	switch len(names) {
+	case 0:
+		tui.ShowError(fmt.Errorf("no command starts with '%v'", name))
+	case 1:
+		command, _ := FindInternalCommand(names[0])
+
+		tui.commandInput.tview.SetText(command.name + " ")
+		tui.ShowMessage(tview.Escape(command.name + " " + command.usage))
+	default:
+		shared := names[0]
+		for _, other := range names[1:] {
+			for !strings.HasPrefix(other, shared) {
+				shared = shared[:len(shared)-1]
+			}
+		}
+
+		tui.commandInput.tview.SetText(shared)
+		tui.ShowMessage(strings.Join(names, "  "))
+	}
+}
+
+// Finish a successful command; show what happened, and return to view-mode
+func (tui *TUI) FinishInternalCommand(message string) {
+	tui.SetMode(ViewMode)
+	tui.ShowMessage(message)
+}
+
+// :set template TEMPLATE changes the command run on each key-stroke
+func RunSetCommand(tui *TUI, args string) error {
+	key, value := ParseInternalCommand(args)
+
+	switch key {
+	case "template":
+		if tui.ctx.argv != nil {
+			return errors.New("templates can't be changed in --exec mode")
+		}
+
+		if value == "" {
+			return errors.New("expected a template, e.g 'set template grep \"$RL_INPUT\" file'")
+		}
+
+		if !tui.ctx.dangerZone {
+			if err := CheckAuditable(tui.ctx.profile); err != nil {
+				return err
+			}
+
+			if err := AuditTemplate(value, tui.ctx.profile, NewInputNames(tui.ctx.fields)); err != nil {
+				return err
+			}
+		}
+
+		*tui.ctx.execute = value
+		tui.history.template = value
+
+		tui.FinishInternalCommand("template set")
+		tui.RunCommand()
+		return nil
+	case "":
+		return errors.New("expected a setting to change, e.g 'set template TEMPLATE'")
+	default:
+		return fmt.Errorf("unknown setting '%v'", key)
+	}
+}
+
+// :env NAME=VALUE provides an environment-variable to the command, replacing any previous value
+func RunEnvCommand(tui *TUI, args string) error {
+	if args == "" {
+		names := []string{}
+		for _, pair := range tui.ctx.envVars {
+			names = append(names, pair[0])
+		}
+
+		tui.FinishInternalCommand("environment variables: " + strings.Join(names, ", "))
+		return nil
+	}
+
+	pair := strings.SplitN(args, "=", 2)
+	if len(pair) != 2 || pair[0] == "" {
+		return fmt.Errorf("expected NAME=VALUE, got '%v'", args)
+	}
+
+	replaced := false
+	for idx, existing := range tui.ctx.envVars {
+		if existing[0] == pair[0] {
+			tui.ctx.envVars[idx] = pair
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		tui.ctx.envVars = append(tui.ctx.envVars, pair)
+	}
+
+	tui.FinishInternalCommand("set $" + pair[0])
+	tui.RunCommand()
+	return nil
+}
+
+// :history opens history-search in edit-mode
+func RunHistoryCommand(tui *TUI, args string) error {
+	tui.SetMode(EditMode)
+	tui.OpenHistorySearch()
+	return nil
+}
+
+// :save NAME saves the template and input, so they can be reopened with rl --rerun NAME
+func RunSaveCommand(tui *TUI, args string) error {
+	if args == "" || strings.ContainsAny(args, " \t") {
+		return errors.New("expected a name without spaces")
+	}
+
+	// --rerun reads a number as the index of a recent session, so a numbered session couldn't be reopened
+	if _, err := strconv.Atoi(args); err == nil {
+		return fmt.Errorf("'%v' is a number; 'rl --rerun %v' reopens a recent session, so choose a name with a letter in it", args, args)
+	}
+
+	err := SaveSession(tui.cfg.SavedPath, SavedSession{
+		Name:       args,
+		Input:      tui.state.lineBuffer.content,
+		Template:   *tui.ctx.execute,
+		Time:       time.Now(),
+		Argv:       tui.ctx.argv,
+		FieldNames: tui.ctx.fields,
+		Fields:     tui.state.lineBuffer.fields,
+	})
+	if err != nil {
+		return err
+	}
+
+	tui.FinishInternalCommand("saved; reopen with 'rl --rerun " + args + "'")
+	return nil
+}
+
+// :wrap on|off toggles line-wrapping in the output
+func RunWrapCommand(tui *TUI, args string) error {
+	switch args {
+	case "on":
+		tui.stdoutViewer.tview.SetWrap(true)
+		tui.stdoutViewer.wrap = true
+	case "off":
+		tui.stdoutViewer.tview.SetWrap(false)
+		tui.stdoutViewer.wrap = false
+	default:
+		return fmt.Errorf("expected 'on' or 'off', got '%v'", args)
+	}
+
+	tui.FinishInternalCommand("wrap " + args)
+	return nil
+}
+
+// :write FILE writes the output shown to a file, without its colours. Output discarded for
+// exceeding the store's limits can't be written, so the message says when it's truncated
+func RunWriteCommand(tui *TUI, args string) error {
+	if args == "" {
+		return errors.New("expected a file to write to")
+	}
+
+	selection := &tui.stdoutViewer.selection
+
+	output := ""
+	if lines := selection.PlainLines(); len(lines) > 0 {
+		output = strings.Join(lines, "\n") + "\n"
+	}
+
+	if err := ioutil.WriteFile(args, []byte(output), USER_READ_WRITE_OCTAL); err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("wrote %v bytes to %v", len(output), args)
+	if written := selection.Written(); written > 0 {
+		message += fmt.Sprintf("; truncated at %v of %v lines", selection.Len(), written)
+	}
+
+	tui.FinishInternalCommand(message)
+	return nil
+}
+
+// :quit exits rl without output
+func RunQuitCommand(tui *TUI, args string) error {
+	tui.Quit()
+	return nil
+}
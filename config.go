@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -59,7 +60,7 @@ func CreateConfigFile(cfg *ConfigOpts) error {
 		}()
 
 		enc := yaml.NewEncoder(cfgConn)
-		encodeErr := enc.Encode(RLConfigFile{false})
+		encodeErr := enc.Encode(RLConfigFile{SaveHistory: false})
 
 		if encodeErr != nil {
 			return encodeErr
@@ -93,10 +94,12 @@ func InitConfig() (*ConfigOpts, error) {
 	configPath := filepath.Join(xdg.ConfigHome, "rl.yaml")
 	dataDir := filepath.Join(xdg.DataHome, "rl")
 	historyPath := filepath.Join(dataDir, "history")
+	savedPath := filepath.Join(dataDir, "saved")
 
 	cfg := ConfigOpts{
 		historyPath,
 		configPath,
+		savedPath,
 		RLConfigFile{},
 	}
 
@@ -182,6 +185,58 @@ func ReadStdin() (*ringbuffer.RingBuffer, int) {
 	return stdin, 0
 }
 
+// Check configuration values are usable
+func CheckConfig(rlCfg *RLConfigFile) error {
+	if rlCfg.DebounceMs < 0 {
+		return fmt.Errorf("debounce_ms must be zero or more, but was %v", rlCfg.DebounceMs)
+	}
+
+	if rlCfg.MaxSpawnsPerSecond < 0 {
+		return fmt.Errorf("max_spawns_per_second must be zero or more, but was %v", rlCfg.MaxSpawnsPerSecond)
+	}
+
+	if rlCfg.StopSignal != "" {
+		if _, err := ParseSignal(rlCfg.StopSignal); err != nil {
+			return fmt.Errorf("stop_signal: %v", err)
+		}
+	}
+
+	if rlCfg.StopGraceMs != nil && *rlCfg.StopGraceMs < 0 {
+		return fmt.Errorf("stop_grace_ms must be zero or more, but was %v", *rlCfg.StopGraceMs)
+	}
+
+	if rlCfg.TimeoutMs < 0 {
+		return fmt.Errorf("timeout_ms must be zero or more, but was %v", rlCfg.TimeoutMs)
+	}
+
+	if rlCfg.FinalTimeoutMs < 0 {
+		return fmt.Errorf("final_timeout_ms must be zero or more, but was %v", rlCfg.FinalTimeoutMs)
+	}
+
+	if rlCfg.MaxOutputLines < 0 {
+		return fmt.Errorf("max_output_lines must be zero or more, but was %v", rlCfg.MaxOutputLines)
+	}
+
+	if rlCfg.MaxOutputBytes < 0 {
+		return fmt.Errorf("max_output_bytes must be zero or more, but was %v", rlCfg.MaxOutputBytes)
+	}
+
+	keys, err := NewKeyBindings(rlCfg.Keys)
+	if err != nil {
+		return err
+	}
+
+	if err := CheckActions(rlCfg.Actions, keys); err != nil {
+		return err
+	}
+
+	if err := CheckHighlightMode(rlCfg.HighlightInput); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // Validate user-configuration before starting RL properly
 func ValidateConfig() (*ConfigOpts, int) {
 	tty, ttyErr := OpenTTY()
@@ -192,6 +247,11 @@ func ValidateConfig() (*ConfigOpts, int) {
 		return cfg, 1
 	}
 
+	if checkErr := CheckConfig(&cfg.Config); checkErr != nil {
+		fmt.Printf("RL: invalid configuration in %v: %v\n", cfg.ConfigPath, checkErr)
+		return cfg, 1
+	}
+
 	if ttyErr != nil {
 		fmt.Printf("RL: could not open /dev/tty. Are you running rl non-interactively?")
 		return cfg, 1
@@ -201,26 +261,155 @@ func ValidateConfig() (*ConfigOpts, int) {
 	return cfg, 0
 }
 
-// Read the user's SHELL variable from the environment; this will normally be bash or zsh. If it's present,
-// just assume it's accurate, the user would have to lie for it to be set incorrectly most likely
-func ReadShell() (string, int) {
+// Read the user's shell from --shell, the shell configuration key, or the SHELL variable, in that order; this
+// will normally be bash or zsh. If it's present, just assume it's accurate, the user would have to lie for it
+// to be set incorrectly most likely
+func ReadShell(opts *docopt.Opts, cfg *ConfigOpts) (string, int) {
 	shell := os.Getenv("SHELL")
+
+	if cfg.Config.Shell != "" {
+		shell = cfg.Config.Shell
+	}
+
+	// docopt is unmaintained
+	if shellIface, present := (*opts)["--shell"]; present && shellIface != nil {
+		shell, _ = opts.String("--shell")
+	}
+
 	if shell == "" {
-		fmt.Printf("RL: could not determine user's shell (e.g bash, zsh). Ensure $SHELL is set.")
+		fmt.Printf("RL: could not determine user's shell (e.g bash, zsh). Ensure $SHELL is set, or use --shell.")
 		return shell, 1
 	}
 
 	return shell, 0
 }
 
-func RLState(opts *docopt.Opts) (LineChangeState, LineChangeCtx, int) {
-	execute, execErr := opts.String("<cmd>")
+// Read the history entry selected by --rerun [<index>]. The index may also
+// be the name of a session saved from command-mode
+func ReadRerun(opts *docopt.Opts, cfg *ConfigOpts) (History, int) {
+	index := 1
 
-	code := AuditCommand(&execute)
-	if code != 0 {
-		return LineChangeState{}, LineChangeCtx{}, code
+	// docopt is unmaintained
+	if indexIface, present := (*opts)["<index>"]; present && indexIface != nil {
+		parsed, indexErr := opts.Int("<index>")
+
+		if indexErr != nil {
+			name, _ := opts.String("<index>")
+			saved, savedErr := FindSavedSession(cfg.SavedPath, name)
+
+			if savedErr != nil {
+				fmt.Printf("RL: could not rerun saved session: %v\n", savedErr)
+				return History{}, 1
+			}
+
+			return History{
+				Input:      saved.Input,
+				Template:   saved.Template,
+				Argv:       saved.Argv,
+				FieldNames: saved.FieldNames,
+				Fields:     saved.Fields,
+			}, 0
+		}
+
+		index = parsed
 	}
 
+	hist, err := RerunHistory(cfg, index)
+
+	if err != nil {
+		fmt.Printf("RL: could not rerun from history: %v\n", err)
+		return History{}, 1
+	}
+
+	return hist, 0
+}
+
+// Read the argv command given after --exec --. Returns nil when commands should run in the user's shell
+func ReadArgv(opts *docopt.Opts) ([]string, int) {
+	exec, execErr := opts.Bool("--exec")
+
+	if execErr != nil || !exec {
+		return nil, 0
+	}
+
+	// docopt is unmaintained
+	argv, castOk := (*opts)["<argv>"].([]string)
+
+	if !castOk || len(argv) == 0 {
+		fmt.Println("RL: --exec expects a command after --, for example 'rl --exec -- rg {}'")
+		return nil, 1
+	}
+
+	return argv, 0
+}
+
+// Names that input fields may have; they're provided to commands as environment-variables
+var ENVAR_NAME_PATTERN = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Read the input-field names given with -f. Returns nil when no fields were named
+func ReadFields(opts *docopt.Opts) ([]string, int) {
+	// docopt is unmaintained
+	names, castOk := (*opts)["--field"].([]string)
+
+	if !castOk || len(names) == 0 {
+		return nil, 0
+	}
+
+	fields := []string{}
+	seen := map[string]bool{}
+
+	for _, name := range names {
+		if !ENVAR_NAME_PATTERN.MatchString(name) {
+			fmt.Printf("RL: input field '%v' must be a valid environment-variable name, like PATTERN or GLOB\n", name)
+			return nil, 1
+		}
+
+		if name == ENVAR_NAME_RL_INPUT {
+			fmt.Printf("RL: input fields can't be named %v; it always holds the first field\n", ENVAR_NAME_RL_INPUT)
+			return nil, 1
+		}
+
+		// docopt repeats values when -f is given several times, so drop duplicates
+		if !seen[name] {
+			seen[name] = true
+			fields = append(fields, name)
+		}
+	}
+
+	return fields, 0
+}
+
+// Read --timeout and --final-timeout, which override the timeouts in RL's configuration
+func ReadTimeouts(opts *docopt.Opts, cfg *ConfigOpts) int {
+	flags := []struct {
+		flag   string
+		target *int
+	}{
+		{"--timeout", &cfg.Config.TimeoutMs},
+		{"--final-timeout", &cfg.Config.FinalTimeoutMs},
+	}
+
+	for _, option := range flags {
+		// docopt is unmaintained
+		if valueIface, present := (*opts)[option.flag]; !present || valueIface == nil {
+			continue
+		}
+
+		value, err := opts.Int(option.flag)
+		if err != nil || value < 0 {
+			fmt.Printf("RL: %v expects a number of milliseconds, zero or more\n", option.flag)
+			return 1
+		}
+
+		*option.target = value
+	}
+
+	return 0
+}
+
+func RLState(opts *docopt.Opts, cfg *ConfigOpts) (LineChangeState, LineChangeCtx, int) {
+	execute, execErr := opts.String("<cmd>")
+
 	if execErr != nil {
 		execute = ""
 	}
@@ -232,13 +421,108 @@ func RLState(opts *docopt.Opts) (LineChangeState, LineChangeCtx, int) {
 		os.Exit(1)
 	}
 
-	_, rerunErr := opts.Bool("--rerun")
+	rerun, rerunErr := opts.Bool("--rerun")
 
 	if rerunErr != nil {
 		fmt.Printf("RL: failed to read --rerun option. %v\n", rerunErr)
 		os.Exit(1)
 	}
 
+	argv, code := ReadArgv(opts)
+	if code != 0 {
+		return LineChangeState{}, LineChangeCtx{}, code
+	}
+
+	fields, code := ReadFields(opts)
+	if code != 0 {
+		return LineChangeState{}, LineChangeCtx{}, code
+	}
+
+	if argv != nil {
+		execute = QuoteArgv(argv)
+	}
+
+	linebuffer := LineBuffer{}
+
+	if fields != nil {
+		linebuffer.fields = make([]string, len(fields))
+	}
+
+	if rerun {
+		// reopen the last session's template, pre-filled with what the user typed
+		hist, code := ReadRerun(opts, cfg)
+		if code != 0 {
+			return LineChangeState{}, LineChangeCtx{}, code
+		}
+
+		execute = hist.Template
+		argv = hist.Argv
+		linebuffer.content = hist.Input
+
+		// input fields are restored with their names, as -f isn't given with --rerun
+		if hist.FieldNames != nil {
+			fields = hist.FieldNames
+			linebuffer.fields = make([]string, len(fields))
+			copy(linebuffer.fields, hist.Fields)
+		}
+	}
+
+	// with no command, rl filters piped standard-input itself
+	filter := ""
+
+	if execute == "" && argv == nil {
+		if code := CheckFilterInput(); code != 0 {
+			return LineChangeState{}, LineChangeCtx{}, code
+		}
+
+		filter = FILTER_FUZZY
+
+		if useRegex, _ := opts.Bool("--regex"); useRegex {
+			filter = FILTER_REGEX
+		}
+	}
+
+	dangerZone, dangerErr := opts.Bool("--danger-zone")
+
+	if dangerErr != nil {
+		fmt.Printf("RL: failed to read --danger-zone option. %v\n", dangerErr)
+		os.Exit(1)
+	}
+
+	if code := ReadTimeouts(opts, cfg); code != 0 {
+		return LineChangeState{}, LineChangeCtx{}, code
+	}
+
+	// argv commands and filters are run without a shell, so one isn't needed
+	shell := ""
+
+	if argv == nil && filter == "" {
+		shell, code = ReadShell(opts, cfg)
+
+		if code != 0 {
+			return LineChangeState{}, LineChangeCtx{}, code
+		}
+	}
+
+	profile := FindShellProfile(shell)
+
+	// the user has explicitly asked us not to check their command. Templates for shells rl doesn't
+	// know can't be checked, so they're refused
+	if !dangerZone && filter == "" {
+		if argv != nil {
+			code = AuditArgvCommand(argv, fields)
+		} else if err := CheckAuditable(profile); err != nil {
+			fmt.Printf("RL: %v\n", err)
+			code = 1
+		} else {
+			code = AuditCommand(&execute, profile, NewInputNames(fields))
+		}
+
+		if code != 0 {
+			return LineChangeState{}, LineChangeCtx{}, code
+		}
+	}
+
 	splitEnvVars := [][]string{}
 
 	// docopt is unmaintained
@@ -266,12 +550,6 @@ func RLState(opts *docopt.Opts) (LineChangeState, LineChangeCtx, int) {
 		}
 	}
 
-	shell, code := ReadShell()
-
-	if code != 0 {
-		return LineChangeState{}, LineChangeCtx{}, code
-	}
-
 	stdin, code := ReadStdin()
 	if code != 0 {
 		return LineChangeState{}, LineChangeCtx{}, code
@@ -279,17 +557,21 @@ func RLState(opts *docopt.Opts) (LineChangeState, LineChangeCtx, int) {
 
 	ctx := LineChangeCtx{
 		shell,
+		profile,
 		inputOnly,
 		&execute,
 		os.Environ(),
 		splitEnvVars,
 		stdin,
+		dangerZone,
+		argv,
+		fields,
+		filter,
 	}
 
-	linebuffer := LineBuffer{}
 	state := LineChangeState{
 		lineBuffer: &linebuffer,
-		cmd:        nil,
+		run:        nil,
 	}
 
 	return state, ctx, 0
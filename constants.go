@@ -1,9 +1,50 @@
 package main
 
-const ENVAR_NAME_RL_INPUT = "RL_INPUT" // The environmental-variable name provided to the subcommand passed to execute
-const STDIN_BUFFER_SIZE = 100_000_000  // The size of the stdin buffer, in bytes
-const USER_WRITE_OCTAL = 00200         // User write file permissions for a file
-const USER_READ_WRITE_OCTAL = 0600     // User read-write file permissions for a file
+const ENVAR_NAME_RL_INPUT = "RL_INPUT"  // The environmental-variable name provided to the subcommand passed to execute
+const ARGV_INPUT_PLACEHOLDER = "{}"     // Replaced with the user's input in each argument, in --exec mode
+const STDIN_BUFFER_SIZE = 100_000_000   // The size of the stdin buffer, in bytes
+const USER_WRITE_OCTAL = 00200          // User write file permissions for a file
+const USER_READ_WRITE_OCTAL = 0600      // User read-write file permissions for a file
+const HISTORY_MAX_LINE_SIZE = 1_000_000 // The longest history-file line RL will read, in bytes
+const DEFAULT_STOP_GRACE_MS = 500       // How long a stopped command has to exit before it's sent SIGKILL, by default
+const TIMEOUT_EXIT_CODE = 124           // The exit-code used when the final command times out, matching timeout(1)
+
+const PTY_DEFAULT_COLUMNS = 80 // The width of a preview command's pseudo-terminal before the output viewer is drawn
+const PTY_DEFAULT_ROWS = 24    // The height of a preview command's pseudo-terminal before the output viewer is drawn
+const PTY_DRAIN_MS = 250       // How long output is copied from a pseudo-terminal after its command exits
+
+const GUTTER_COLUMNS = 2     // The width of the gutter marking lines in view mode
+const MOUSE_SCROLL_LINES = 3 // How many lines the output scrolls for each step of the mouse-wheel
+
+const KEY_UNBOUND = "none"  // Binding a key to this in the keys configuration unbinds it
+const HELP_BAR_MAX_KEYS = 2 // The most keys the help-bar shows for each action
+
+const OSC52_MAX_BYTES = 100_000    // The longest OSC 52 sequence rl writes; many terminals ignore longer ones
+const TRANSIENT_MESSAGE_MS = 2_000 // How long a confirmation, like "copied 3 lines", stays in the help-bar
+const CLIPBOARD_TIMEOUT_MS = 5_000 // How long clipboard_command may run before it's stopped
+
+const DEFAULT_MAX_OUTPUT_LINES = 100_000    // The most lines of a preview command's output rl keeps, by default
+const DEFAULT_MAX_OUTPUT_BYTES = 64_000_000 // The most bytes of a preview command's output rl keeps, by default
+
+const FUZZY_SCORE_MATCH = 16      // Score for each matched character
+const FUZZY_BONUS_CONSECUTIVE = 8 // Bonus for a character matched directly after the previous match
+const FUZZY_BONUS_BOUNDARY = 8    // Bonus for a character matched at the start of a word
+const FUZZY_PENALTY_GAP_START = 3 // Penalty for starting a gap between matches
+const FUZZY_PENALTY_GAP = 1       // Penalty for each character in a gap between matches
+
+const HIGHLIGHT_OFF = "off"          // The current input isn't highlighted in the output
+const HIGHLIGHT_LITERAL = "literal"  // The current input is highlighted wherever it appears in the output
+const HIGHLIGHT_REGEX = "regex"      // The current input is highlighted wherever it matches as a regular-expression
+const INPUT_HIGHLIGHT_TAG = "[::bu]" // How the current input is drawn in the output; bold and underlined, keeping its colour
+
+const FILTER_MAX_LINES = 1000 // The most matching lines shown at once in filter mode
+const FILTER_REFRESH_MS = 200 // How often filter mode checks whether more standard-input has arrived
+const FILTER_FUZZY = "fuzzy"  // Filter mode matching lines fuzzily
+const FILTER_REGEX = "regex"  // Filter mode matching lines with a regular-expression
+
+const HISTORY_SEARCH_MAX_HITS = 500            // The most history-search results shown at once
+const HISTORY_SEARCH_TEMPLATE_WIDTH = 40       // The widest a template is shown in history-search results
+const HISTORY_TIME_FORMAT = "2006-01-02 15:04" // How history times are shown
 
 type PromptMode int
 
@@ -14,15 +55,24 @@ const (
 	HelpMode
 )
 
-const PROMPT_EDIT = "edit    | > " // The RL prompt for viewing text
-const PROMPT_VIEW = "view    |   " // The RL prompt for executing a command
-const PROMPT_HELP = "help    |   " // The RL prompt for showing help
-const PROMPT_CMD = "command | > "  // The RL prompt for running internal commands
+// How templates for a shell are checked for dangerous uses of user-input
+type ShellAudit int
 
-const HELP_COMMAND = "press [green]ESCAPE[-:-:-] or  [green]q[-:-:-] to quit, [green]/[-:-:-] to switch to edit input, [green]:[-:-:-] to enter commands, [green]?[-:-:-] for help"
-const HELP_EDIT = "press [green]ESCAPE[-:-:-] to switch to view mode, [green]ENTER[-:-:-] to exit with command-output"
-const HELP_VIEW = "press [green]ESCAPE[-:-:-] or  [green]q[-:-:-] to quit, [green]/[-:-:-] to switch to edit input, [green]:[-:-:-] to enter commands, [green]?[-:-:-] for help"
-const HELP_HELP = "press [green]ESCAPE[-:-:-] or  [green]q[-:-:-] to quit, [green]/[-:-:-] to switch to edit input, [green]:[-:-:-] to enter commands"
+const (
+	NoAudit    ShellAudit = iota // the shell isn't one rl knows, so its templates can't be checked
+	PosixAudit                   // templates are parsed as POSIX shell, and checked fully
+	WordAudit                    // templates are split into commands and words, and checked for what each command runs
+)
+
+const PROMPT_EDIT = "edit    | > "   // The RL prompt for viewing text
+const PROMPT_VIEW = "view    |   "   // The RL prompt for executing a command
+const PROMPT_HELP = "help    |   "   // The RL prompt for showing help
+const PROMPT_CMD = "command | > "    // The RL prompt for running internal commands
+const PROMPT_SEARCH = "search  | > " // The RL prompt for searching history
+
+const DANGER_ZONE_BANNER = "[white:red:b] DANGER ZONE: safety checks disabled [-:-:-]" // Shown in the header while --danger-zone is enabled
+
+const HELP_SEARCH = "press [green]CTRL-R[-:-:-] for the next match, [green]ENTER[-:-:-] to use the selected input, [green]ESCAPE[-:-:-] to cancel"
 
 const DefaultViewerText = `
 RL - run commands on key-stroke
@@ -35,14 +85,15 @@ Please read RL's "Please Be Careful" documentation
 `
 
 const ModesDocumentation = `
-RL supports several "modes": edit-mode, command-mode, view-mode, and help-mode.
+RL supports several "modes": edit-mode, command-mode, view-mode, and help-mode. The keys below are the
+defaults; help-mode lists the keys in use, which can be changed with the keys configuration.
 
 Edit-Mode
 =============
 
   Run commands on key-stroke. Takes the command you provided as an argument, and substitutes
   $RL_INPUT with whatever you type in. Output
-  (stdout, stderr) is shown on-screen. Press Enter to run the command, output to
+  (stdout, stderr) is shown on-screen, with standard-error in its own pane. Press Enter to run the command, output to
   (stdout, stderr), and exit RL.
 
   If you run into issues with missing output, see 'Output' section of this
@@ -55,6 +106,14 @@ Edit-Mode
   - Enter        output to stdout + stderr and exit
   - Backspace    delete char before cursor
   - Delete       delete char after cursor
+  - Ctrl-R       search history for a previous input. Type to fuzzy-search inputs, commands, and
+                   templates; Ctrl-R, Up, Down change the selected input, which is run as it's selected.
+                   Press Enter to keep the selected input, or Escape to restore what you were typing
+  - Tab          with several input fields (-f), move to the next field. Shift-Tab moves to the previous field.
+                   Editing any field re-runs the command
+  - Up, Down     show the previous, next input from history. Inputs for the current command
+                   are shown first, followed by inputs for other commands. Scrolling down past
+                   the newest input restores what you were typing
 
   Cursor Navigation:
   - Left, Right              move cursor left, right
@@ -62,37 +121,97 @@ Edit-Mode
   - End, Ctrl-E, Alt-E       end-of-line
   - Ctrl-Left, Ctrl-Right    move one word left, right
 
+  Filtering:
+  When lines are piped into rl and no <cmd> is given, e.g 'ls | rl', rl filters those lines itself rather than
+  running a command on each keystroke. Lines fuzzy-match what you type, best match first, with matched characters
+  highlighted; '--regex' matches them with a regular-expression instead. Matching ignores case unless you type
+  an upper-case character. The header shows how many lines matched. Enter prints the best match and exits; rl
+  exits with code 1 if nothing matched.
+
 Command-Mode
 =============
 
-  Modifies RL's behaviour through commands.
+  Modifies RL's behaviour through commands, which are run by RL rather than your shell.
+  Press Tab to complete a command-name, Enter to run it, and Escape to return to view-mode.
+
+  - set template TEMPLATE    replace the command run on each key-stroke
+  - env NAME=VALUE           provide an environment-variable to the command. With no argument,
+                               list the variables provided
+  - find TEXT                search the output for TEXT, highlighting each match and moving the cursor to
+                               the first match at or below it. With no argument, stop searching
+  - highlight literal|regex|off
+                             highlight the current input in the output, matched as text or a regular-expression.
+                               With no argument, toggle highlighting on or off
+  - history                  search history for a previous input
+  - save NAME                save the command and input; reopen them with 'rl --rerun NAME'. Names can't be numbers
+  - wrap on|off              wrap long lines of output, or don't
+  - write FILE               write the output shown to a file, without its colours. Output past max_output_lines or
+                               max_output_bytes isn't kept, so isn't written either
+  - yank [all]               copy the marked lines, or the line under the cursor, to the clipboard. With 'all',
+                               copy the whole output
+  - quit                     exit without output
 
 View-Mode
 =========
 
   Scroll through command-output text. This is useful when a command produces a
   lot of output, for example grepping a log-file. Line-position is shown in the
-  top left corner of rl
+  top left corner of rl, and counts standard-output lines only; it updates while a
+  command is still writing, and counts a final line without a trailing newline. The exit-code of
+  the last command is shown alongside its latency; green for success, red otherwise.
+  Standard-error is shown in a separate pane below the output.
 
   - Escape, q    quit without output
   - /            switch to edit-mode
   - :            switch to command-mode
   - ?            switch to help-mode
+  - e            show or hide the standard-error pane
+  - Space, Tab   mark or unmark the line under the cursor, and move to the next line. Marked lines are
+                   shown with a * in the gutter
+  - Enter        print the marked lines and exit, rather than running the command again. With nothing
+                   marked, Enter runs the command and exits as in edit-mode, unless select_on_enter is set;
+                   then it prints the line under the cursor. When filtering standard-input it always does
+  - f            search the output; opens command-mode with 'find '. Matching ignores case unless the
+                   search contains an upper-case character, and the header shows which match the cursor is on
+  - n, N         move the cursor to the next, previous search match
+  - y            copy the marked lines to the clipboard, or the line under the cursor if none are marked
+  - Y            copy the whole output to the clipboard
+  - other keys   run the action bound to the key in the actions configuration against the line under the cursor
 
   Text Navigation
   =============
 
-  - Up, k                 scroll up
-  - Down, j               scroll down
-  - g                     move to top
-  - G                     move to bottom
-  - Page Up, Page down    scroll faster
+  - Up, k                 move the cursor up
+  - Down, j               move the cursor down
+  - g, Home               move to the first line
+  - G, End                move to the last line
+  - Ctrl-U, Ctrl-D        move half a page up, down
+  - Page Up, Page down    move a page up, down. Ctrl-B and Ctrl-F do the same
+  - Left, h, Right, l     scroll sideways, when wrapping is off (':wrap off')
+  - NN                    type a count before a key to repeat it; 5j moves down five lines. Before g or G, the
+                            count is a line to jump to; 120g moves to line 120
+
+  The header shows the first and last lines on screen, counting a wrapped line once.
+
+  Mouse
+  =============
+
+  - Wheel                 scroll the output, without moving the cursor
+  - Click                 move the cursor to a line, switching to view mode
+  - Double-click          mark or unmark a line
+  - Click an input        edit the input
+
+  Set disable_mouse to select text with the mouse as your terminal normally would.
 `
 
 const OutputDocumentation = `
 Output:
 
-  Commands like grep, awk, sed, and jq buffer their output
+  Commands like grep, awk, sed, and jq buffer their output when it isn't written to a terminal, so
+  output from slow commands arrives in large chunks, and many tools won't colour it. Set preview_pty
+  to run preview commands in a pseudo-terminal sized to the output area; tools then stream
+  line-by-line and emit colour. Standard-error is mixed into the output either way. The final run,
+  when ENTER is pressed, always writes to real pipes so redirection downstream behaves normally.
 
 `
 
@@ -129,7 +248,7 @@ History
 =======
 
   ~/.local/share/rl/history    If enabled, RL will save each executed command to a history file
-                               in JSON format.
+                               in JSON format. 'rl --rerun' reopens the most recent session from this file.
 
 `
 
@@ -137,10 +256,15 @@ const EnvironmentalVariables = `
 Environment Variables
 =====================
 
-  $SHELL           rl starts a command in the user's default-shell.
+  $SHELL           rl starts a command in the user's default-shell, unless --shell or the shell
+                     configuration key are set. rl knows how sh, bash, zsh, ksh, dash, fish, nushell (nu),
+                     and PowerShell (pwsh) take a script; other shells are assumed to take -c, like sh, but
+                     their commands can't be checked, so they're only run with --danger-zone.
   $RL_INPUT        this variable conwtains the user-input text. Subcommands
-  must use this environmental variable to access user-input.
+  must use this environmental variable to access user-input. Write it in your shell's syntax:
+  $RL_INPUT for POSIX shells and fish, $env.RL_INPUT for nushell, and $env:RL_INPUT for PowerShell.
   <env_vars...>    additional variables provided to rl
+  -f <name>        each input field is provided as a variable with its name; $RL_INPUT holds the first field
 `
 
 const Configuration = `
@@ -149,8 +273,61 @@ Configuration
 
   ~/.config/rl.yaml    RL can be configured in this YAML file. The options are:
 
-  save_history    a boolean value. Should command-execution history be saved to a history file?
-                    Defaults to false.
+  save_history             a boolean value. Should command-execution history be saved to a history file?
+                             Defaults to false.
+  debounce_ms              how long typing must pause before a command runs, in milliseconds. Defaults to 0,
+                             which runs a command on every key-stroke.
+  max_spawns_per_second    the most commands rl will start in any one second; further runs are deferred
+                             until allowed. Defaults to 0, which is unlimited.
+  shell                    the shell commands run in, by name or path. Defaults to $SHELL.
+  stop_signal              the signal sent to a command that's still running when input changes. One of SIGTERM,
+                             SIGINT, SIGHUP, or SIGKILL. Defaults to SIGTERM. Output from a stopped command is
+                             ignored straight away.
+  stop_grace_ms            how long a stopped command has to exit before it's sent SIGKILL, in milliseconds.
+                             Defaults to 500. 0 sends SIGKILL straight after stop_signal.
+  preview_pty              a boolean value. Should preview commands run in a pseudo-terminal, so they stream
+                             output and colour it? Defaults to false.
+  timeout_ms               how long a preview command may run before it's stopped, in milliseconds. The header
+                             shows "timed out after Nms" instead of the latency. Defaults to 0, which is unlimited.
+  final_timeout_ms         how long the command run when ENTER is pressed may run before it's stopped, in
+                             milliseconds; rl then exits with code 124. Defaults to 0, which is unlimited.
+  actions                  commands run against the line under the cursor in view mode, by the key that runs them.
+                             For example, 'o: $EDITOR +{line} {file}' opens a grep or rg match in your editor.
+                             {file}, {line}, and {column} are read from a path:line: or path:line:column: prefix;
+                             lines without one are taken to be a file-name. {text} is the whole line. Each is
+                             passed quoted, as $RL_FILE, $RL_LINE, $RL_COLUMN, and $RL_TEXT, so it's never
+                             evaluated; placeholders already in quotes, like '{file}', work too. Actions run in sh,
+                             using the terminal; rl returns to view mode when they exit. Keys view mode already
+                             uses can't be bound.
+  highlight_input          highlight what you've typed wherever it appears in the output; 'literal' matches it as
+                             text, and 'regex' as a regular-expression. Matching ignores case unless the input
+                             contains an upper-case character. The command's own colours are kept. Defaults to
+                             off; the highlight command toggles it.
+  max_output_lines         the most lines of a preview command's output rl keeps. Output past this is discarded,
+                             and "output truncated at N lines" is shown below the last line kept; the line-position
+                             counts the lines kept, as "N of M" lines written. Defaults to 100000. ENTER still runs
+                             the command again, so the final output is never truncated.
+  max_output_bytes         the most bytes of a preview command's output rl keeps, as with max_output_lines.
+                             Defaults to 64000000.
+  clipboard_command        a fallback command y and Y copy text with, like 'wl-copy' or 'xclip -selection clipboard';
+                             it runs in sh, and reads the text from standard-input. Text is copied with the OSC 52
+                             escape-sequence, which works over SSH, and in tmux with 'set-clipboard on'. This command
+                             is used when OSC 52 can't be: for more than about 75KB, or when the terminal can't be
+                             opened. It's stopped if it runs for more than 5 seconds.
+  disable_mouse            a boolean value. Should rl leave the mouse to the terminal, so text can be selected
+                             as normal? Defaults to false; rl scrolls with the wheel, and moves to clicked lines.
+  keys                     bind keys to actions, by mode. For example, under 'keys:', 'view: {ctrl-o: quit, x: toggle-mark}'.
+                             Modes are edit, view, command, and help; the actions in each are listed under
+                             'Key Bindings' in help-mode. Keys are written like ctrl-o, alt-x, enter, esc, tab,
+                             space, up, pgdn, f1, or a character like G; quote characters YAML treats specially,
+                             like ':'. A key bound here replaces its default action; bind it to none to unbind it.
+                             Characters can't be bound in edit and command mode, as they're typed into the input,
+                             and digits can't be bound in view mode, as they type a count.
+  select_on_enter          a boolean value. Should ENTER in view mode print the line under the cursor, rather
+                             than run the command again? Marked lines are printed either way. Defaults to false.
+
+  When a run is waiting on debounce_ms or max_spawns_per_second, the latency shown in the header
+  reads "deferred".
 
 `
 
@@ -164,6 +341,12 @@ Arguments
                                            variable "$folder" would be available to the supplied command to search or list.
   <cmd>                                  execute a utility command whenever user input changes; the current line will
                                          be available as the line $RL_INPUT
+  <argv>...                              with --exec, the command to run and its arguments. {} in any argument is replaced
+                                           with the user's input, and {NAME} with the input field NAME given with -f; the
+                                           command is run directly, without a shell.
+  <index>                                which session --rerun should reopen. 1, the default, is the most recent session;
+                                           2 is the session before that, and so on. The name of a session saved with
+                                           the ':save' command can be used instead.
 
 Options:
   -i, --input-only                       by default,
@@ -175,7 +358,26 @@ Options:
                                            cause unintented system-destruction. Rl can only spot some dangerous usage; the
                                            responsibility to use rl carefully lies with you, with or without
                                            danger-zone enabled. See "Please Be Careful" section of the documentation for
-                                           more information.
+                                           more information. While enabled, rl shows a warning in its header, and
+                                           history records that the session ran without safety checks.
+  --exec                                 run <argv> directly rather than in the user's shell, substituting the input for {}.
+                                           The input is always passed as part of a single argument, so it can't be split
+                                           into words, globbed, or evaluated; $SHELL isn't needed.
+  -f, --field <name>                     add a named input field; give -f several times for several fields, e.g
+                                           'rl -f PATTERN -f GLOB 'rg "$PATTERN" -g "$GLOB"''. Each field is provided to
+                                           the command as an environment-variable with its name, and $RL_INPUT holds
+                                           the first field; with --exec, {NAME} in an argument is replaced with it. Tab
+                                           and Shift-Tab move between fields.
+  --shell=<shell>                        the shell <cmd> runs in, e.g fish or /usr/bin/nu. Overrides the shell configuration key
+                                           and $SHELL.
+  --timeout=<ms>                         stop a preview command that's still running after this many milliseconds, and
+                                           show "timed out" in the header instead of its latency. Overrides timeout_ms.
+  --final-timeout=<ms>                   stop the command run when ENTER is pressed if it's still running after this many
+                                           milliseconds; rl then exits with code 124. Overrides final_timeout_ms.
+  --regex                                with no <cmd>, filter standard-input lines with a regular-expression rather than
+                                           fuzzily.
+  -r, --rerun                            reopen rl with the template and input from a previous session, read from
+                                           the history file. Requires save_history to be enabled.
   - h, --help                            show this documentation
 `
 
@@ -195,6 +397,22 @@ Please Be Careful
   - Always quote $RL_INPUT to avoid word-expansion; this could lead to unexpected evaluation
   - Do not assume you are vigilant enough to ignore these warnings; people fuck up, often.
 
+  Before starting, RL parses <cmd> as shell and refuses to run it if $RL_INPUT is unquoted, evaluated by eval or 'sh -c',
+  piped into a shell, evaluated as arithmetic, used as a command-name, names a file that is written to, or is an argument
+  to a destructive command like rm, mv, dd, chmod, or truncate. Wrappers like sudo, env, nice, and xargs are looked past
+  to find the command they run, and scripts passed to 'sh -c' are checked too. Each problem is shown with its line and
+  column.
+
+  Commands for fish, nushell, and PowerShell can't be parsed fully, so RL splits them into commands and words, and only
+  checks whether the input is used as a command-name, evaluated, piped into a shell, names a file that is written to, or
+  is an argument to a destructive command; take extra care with them. RL can't check commands for other shells at all,
+  so it refuses to run them unless --danger-zone is given.
+
+  With --exec, the command is run without a shell, so the input can't be split, globbed, or evaluated by one. RL still
+  refuses to run it if {} or an input field's {NAME} is used as the command-name, in a script passed to 'sh -c', or as an
+  argument to a destructive command, looking past wrappers like sudo, env, and xargs. Scripts passed to 'sh -c' are
+  checked like templates.
+
   RL includes some safety-nets to avoid you running into these problems blindly, but it's not omniscience. Use rl for grep, awk, sed,
   jq, fdfind, and other filtering operations and it will speed up your workflow; use it for rm and it'll uninstall itself (and everything
   else on your system) eventually.
@@ -207,8 +425,10 @@ rl (readline) is an interactive line-editor.
 const UsageLine = `
 rl
 Usage:
-  rl [-i|--input-only] [--danger-zone] <cmd> [<env_vars>...]
-  rl (-r|--rerun) [--danger-zone]
+  rl [-i|--input-only] [--danger-zone] [--timeout=<ms>] [--final-timeout=<ms>] [--shell=<shell>] [(-f <name>)]... <cmd> [<env_vars>...]
+  rl [-i|--input-only] [--danger-zone] [--timeout=<ms>] [--final-timeout=<ms>] [(-f <name>)]... --exec -- <argv>...
+  rl [--regex]
+  rl (-r|--rerun) [<index>] [--danger-zone] [--timeout=<ms>] [--final-timeout=<ms>] [--shell=<shell>]
   rl (-h|--help)
 `
 
@@ -224,23 +444,20 @@ const Usage = UsageLine +
 	License +
 	SeeAlso
 
-const HelpDocumentation = `
+const HelpIntroduction = `
 Rl is an interactive command-runner
 
 For questions, feature, bug, or documentation tickets use
 
 https://github.com/rgrannell1/rl/issues
 
-` +
-	ModesDocumentation +
-	OutputDocumentation +
-	Configuration +
-	HistoryDocs +
-	EnvironmentalVariables +
-	PleaseBeCareful
+`
+
+const LATENCY_COLUMNS = 24 // The width of the latency header; wide enough for "timed out after 10000ms"
 
 const COMMAND_AND_LINE_ROWS = 2
 const STDOUT_ROWS = 0
+const STDERR_MAX_ROWS = 6 // The tallest the standard-error pane grows; it's hidden when there's no standard-error
 const SPACE_ROWS = 1
 const HELP_ROWS = 1
 const COMMAND_ROWS = 1
@@ -265,5 +482,8 @@ const MINHEIGHT_0 = 0
 const MINWIDTH_0 = 0
 const MINWIDTH_1 = 1
 
+const PAGE_MAIN = "main"                     // The page containing RL's main grid
+const PAGE_HISTORY_SEARCH = "history-search" // The page containing the history-search overlay
+
 const FOCUS = true
 const DONT_FOCUS = false
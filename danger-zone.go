@@ -1,30 +1,846 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
 )
 
-/*
-Shell parse-tree
+var IDENTIFIER_PATTERN = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// A dangerous use of user-input found in a command template
+type AuditFinding struct {
+	line   uint   // the line in the template where user-input was used
+	col    uint   // the column in that line
+	reason string // why this usage is dangerous
+}
+
+// A finding at a position in a parsed template
+func findingAt(pos syntax.Pos, reason string) AuditFinding {
+	return AuditFinding{pos.Line(), pos.Col(), reason}
+}
+
+func (finding AuditFinding) String() string {
+	return fmt.Sprintf("%v:%v: %v", finding.line, finding.col, finding.reason)
+}
+
+// Shells that evaluate a script passed with -c
+var EVALUATING_SHELLS = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "mksh": true, "fish": true, "nu": true,
+	"pwsh": true, "powershell": true,
+}
+
+// Commands that evaluate their arguments as code
+var EVALUATING_COMMANDS = map[string]bool{
+	"eval": true, "source": true, ".": true, "invoke-expression": true, "iex": true,
+}
+
+// Commands that delete, overwrite, or alter files or processes named by their arguments
+var DESTRUCTIVE_COMMANDS = map[string]bool{
+	"rm": true, "rmdir": true, "unlink": true, "shred": true, "mv": true, "cp": true, "dd": true,
+	"chmod": true, "chown": true, "chgrp": true, "truncate": true, "mkfs": true, "kill": true,
+	"pkill": true, "killall": true, "remove-item": true, "del": true, "move-item": true, "copy-item": true,
+	"set-content": true, "clear-content": true, "stop-process": true,
+}
+
+// How a command that runs another command takes its arguments
+type WrapperCommand struct {
+	shortValues string   // short flags that take the next argument as their value, e.g n for 'nice -n 5'
+	longValues  []string // long flags that take the next argument as their value, when it isn't given with =
+	operands    int      // how many arguments come before the command, e.g timeout's duration
+	assigns     bool     // does it take variable assignments before the command, like env?
+}
+
+// Commands that run the command given in their arguments; we look past these to find what is really run
+var WRAPPER_COMMANDS = map[string]WrapperCommand{
+	"sudo":         {"CDghpRrTtUu", []string{"--chdir", "--chroot", "--close-from", "--command-timeout", "--group", "--host", "--other-user", "--prompt", "--role", "--type", "--user"}, 0, false},
+	"doas":         {"Cu", nil, 0, false},
+	"command":      {"", nil, 0, false},
+	"builtin":      {"", nil, 0, false},
+	"exec":         {"a", nil, 0, false},
+	"env":          {"CSu", []string{"--chdir", "--split-string", "--unset"}, 0, true},
+	"nice":         {"n", []string{"--adjustment"}, 0, false},
+	"nohup":        {"", nil, 0, false},
+	"setsid":       {"", nil, 0, false},
+	"time":         {"fo", []string{"--format", "--output"}, 0, false},
+	"timeout":      {"ks", []string{"--kill-after", "--signal"}, 1, false},
+	"stdbuf":       {"eio", []string{"--error", "--input", "--output"}, 0, false},
+	"&":            {"", nil, 0, false},
+	"run-external": {"", nil, 0, false},
+	"xargs":        {"adEILnPs", []string{"--arg-file", "--delimiter", "--max-args", "--max-chars", "--max-lines", "--max-procs", "--process-slot-var"}, 0, false},
+}
+
+// Redirections that write to the file they name
+var WRITING_REDIRECTS = map[syntax.RedirOperator]bool{
+	syntax.RdrOut: true, syntax.AppOut: true, syntax.RdrInOut: true, syntax.ClbOut: true,
+	syntax.RdrAll: true, syntax.AppAll: true,
+}
+
+// Redirections that feed text to a command's standard-input
+var FEEDING_REDIRECTS = map[syntax.RedirOperator]bool{
+	syntax.Hdoc: true, syntax.DashHdoc: true, syntax.WordHdoc: true,
+}
+
+// Comparisons in [[ ]] that evaluate both sides as arithmetic
+var ARITHMETIC_TESTS = map[syntax.BinTestOperator]bool{
+	syntax.TsEql: true, syntax.TsNeq: true, syntax.TsLeq: true, syntax.TsGeq: true, syntax.TsLss: true,
+	syntax.TsGtr: true,
+}
+
+// The variables holding user-input; $RL_INPUT, and any named input fields
+type InputNames map[string]bool
+
+// List the variables holding user-input
+func NewInputNames(fields []string) InputNames {
+	names := InputNames{ENVAR_NAME_RL_INPUT: true}
+	for _, field := range fields {
+		names[field] = true
+	}
+
+	return names
+}
+
+// The variables holding user-input in a script run by 'sh -c', whose arguments are its positional-parameters
+// from $0 onwards; any argument holding user-input makes that parameter, $@, and $* hold it too
+func (names InputNames) withPositional(args []AuditWord) InputNames {
+	scriptNames := InputNames{}
+	for name := range names {
+		scriptNames[name] = true
+	}
+
+	for idx, arg := range args {
+		if arg.input != "" {
+			scriptNames[strconv.Itoa(idx)] = true
+			scriptNames["@"] = true
+			scriptNames["*"] = true
+		}
+	}
+
+	return scriptNames
+}
+
+// Is this parameter-expansion a reference to user-input? Returns the variable's name
+func (names InputNames) isInputExpansion(node syntax.Node) (string, bool) {
+	param, ok := node.(*syntax.ParamExp)
+	if !ok || param.Param == nil || !names[param.Param.Value] {
+		return "", false
+	}
+
+	return param.Param.Value, true
+}
+
+// A reference to user-input in a template
+type inputRef struct {
+	pos  syntax.Pos // where the variable was used
+	name string     // the variable's name
+}
+
+// Find the first reference to user-input anywhere in a node, including inside quotes, command-substitutions,
+// and arithmetic, where variables can be named without a $
+func (names InputNames) findInputExpansion(node syntax.Node) (inputRef, bool) {
+	var found inputRef
+	ok := false
+
+	syntax.Walk(node, func(node syntax.Node) bool {
+		if ok {
+			return false
+		}
+
+		if arithm, isArithm := node.(*syntax.ArithmExp); isArithm {
+			if refs := names.findArithmInput(arithm.X); len(refs) > 0 {
+				found, ok = refs[0], true
+			}
+		} else if name, isInput := names.isInputExpansion(node); isInput {
+			found, ok = inputRef{node.Pos(), name}, true
+		}
+
+		return !ok
+	})
+
+	return found, ok
+}
+
+// Find references to user-input in an arithmetic expression, with or without a $. Bash evaluates a variable's
+// value there as an expression too, and array subscripts in that expression can run commands
+func (names InputNames) findArithmInput(expr syntax.Node) []inputRef {
+	found := []inputRef{}
+
+	if expr == nil {
+		return found
+	}
+
+	syntax.Walk(expr, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.Word:
+			// variables are named by bare words in arithmetic, and quoted expressions are evaluated too, as in
+			// let "count = RL_INPUT"
+			text, _ := literalParts(node.Parts)
+
+			for _, name := range IDENTIFIER_PATTERN.FindAllString(text, -1) {
+				if names[name] {
+					found = append(found, inputRef{node.Pos(), name})
+					break
+				}
+			}
+		case *syntax.ParamExp:
+			if name, ok := names.isInputExpansion(node); ok {
+				found = append(found, inputRef{node.Pos(), name})
+			}
+		case *syntax.CmdSubst, *syntax.ArithmExp:
+			// these are checked separately
+			return false
+		}
+
+		return true
+	})
+
+	return found
+}
+
+// Find references to user-input directly in a word, outside of quotes. These are
+// split into words and glob-expanded by the shell
+func (names InputNames) findUnquotedInput(word *syntax.Word) []inputRef {
+	found := []inputRef{}
+
+	for _, part := range word.Parts {
+		if name, ok := names.isInputExpansion(part); ok {
+			found = append(found, inputRef{part.Pos(), name})
+		}
+	}
+
+	return found
+}
+
+// Explain that a variable is unquoted
+func unquotedFinding(ref inputRef) AuditFinding {
+	return findingAt(ref.pos, "$"+ref.name+" is unquoted, so it will be split into words and glob-expanded. Quote it as \"$"+ref.name+"\"")
+}
+
+// Explain that a variable is evaluated as arithmetic
+func arithmFinding(ref inputRef) AuditFinding {
+	return findingAt(ref.pos, "$"+ref.name+" is evaluated as an arithmetic expression, which can run commands")
+}
+
+// A word of a command, reduced to what's needed to work out what the command runs
+type AuditWord struct {
+	text    string // the word's text, if it's entirely literal
+	literal bool   // is the word entirely literal text, without any expansions?
+	prefix  string // the literal text the word starts with, e.g --user= in --user="$RL_INPUT"
+	input   string // how the first user-input in the word is referred to, e.g $RL_INPUT; empty if it has none
+}
+
+// A dangerous use of user-input in one of a command's words
+type wordFinding struct {
+	word   int    // the index of the word
+	reason string // why this usage is dangerous
+}
+
+// Get the literal text of a word's parts, stopping at the first part that isn't literal. Returns whether
+// every part was literal
+func literalParts(parts []syntax.WordPart) (string, bool) {
+	text := ""
+
+	for _, part := range parts {
+		switch part := part.(type) {
+		case *syntax.Lit:
+			text += part.Value
+		case *syntax.SglQuoted:
+			if part.Dollar {
+				return text, false
+			}
+			text += part.Value
+		case *syntax.DblQuoted:
+			inner, literal := literalParts(part.Parts)
+			text += inner
+
+			if !literal {
+				return text, false
+			}
+		default:
+			return text, false
+		}
+	}
+
+	return text, true
+}
+
+// Reduce a word of a parsed command to an AuditWord, and the position of its user-input or of the word itself
+func (names InputNames) auditWord(word *syntax.Word) (AuditWord, syntax.Pos) {
+	prefix, literal := literalParts(word.Parts)
+	audited := AuditWord{prefix: prefix, literal: literal}
+
+	if literal {
+		audited.text = prefix
+	}
+
+	if ref, ok := names.findInputExpansion(word); ok {
+		audited.input = "$" + ref.name
+		return audited, ref.pos
+	}
+
+	return audited, word.Pos()
+}
+
+// Is this argument a cluster of short flags, e.g -xc?
+func isShortFlags(arg string) bool {
+	if len(arg) < 2 || arg[0] != '-' || arg[1] == '-' {
+		return false
+	}
+
+	return strings.IndexFunc(arg[1:], func(char rune) bool {
+		return !(char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z')
+	}) == -1
+}
+
+// Does this flag take the next argument as its value? Short flags can be clustered, e.g 'sudo -Eu root'; the
+// first that takes a value uses the rest of the cluster, or the next argument if it's last
+func (wrapper WrapperCommand) takesValue(flag string) bool {
+	if strings.HasPrefix(flag, "--") {
+		for _, long := range wrapper.longValues {
+			if flag == long {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for idx, char := range flag[1:] {
+		if strings.ContainsRune(wrapper.shortValues, char) {
+			return idx == len(flag)-2
+		}
+	}
+
+	return false
+}
+
+// Find the word after a wrapper's flags, flag-values, variable assignments, and operands
+func (wrapper WrapperCommand) skipArgs(words []AuditWord, idx int) int {
+	operands := wrapper.operands
+	flags := true
+
+	for ; idx < len(words); idx++ {
+		word := words[idx]
+
+		switch {
+		case flags && word.literal && word.text == "--":
+			flags = false
+		case flags && len(word.prefix) > 1 && word.prefix[0] == '-':
+			if word.literal && wrapper.takesValue(word.text) {
+				idx++
+			}
+		case wrapper.assigns && isAssignment(word.prefix):
+		case operands > 0:
+			operands--
+		default:
+			return idx
+		}
+	}
+
+	return idx
+}
+
+// Does this text start with a variable assignment, e.g FOO=bar?
+func isAssignment(text string) bool {
+	equals := strings.Index(text, "=")
+	if equals < 1 {
+		return false
+	}
+
+	return strings.IndexFunc(text[:equals], func(char rune) bool {
+		return !(char == '_' || char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9')
+	}) == -1
+}
+
+// Find which word names the command being run, looking past wrappers like sudo or xargs, their flags and
+// operands, and env's variable assignments. This word might hold user-input, in which case the input is the
+// command. Returns -1 if there's no command, or it isn't literal text
+func commandWord(words []AuditWord) int {
+	idx := 0
+
+	for idx < len(words) {
+		word := words[idx]
+
+		if word.input != "" {
+			return idx
+		}
+
+		if !word.literal {
+			return -1
+		}
+
+		wrapper, isWrapper := WRAPPER_COMMANDS[filepath.Base(word.text)]
+		if !isWrapper {
+			return idx
+		}
+
+		idx = wrapper.skipArgs(words, idx+1)
+	}
+
+	return -1
+}
+
+// Is this argument the flag that makes a shell run an inline script? That's -c, including in a cluster of
+// short flags like -xc, or --command; PowerShell takes -Command or any abbreviation of it
+func isScriptFlag(shell string, arg string) bool {
+	lower := strings.ToLower(arg)
+
+	if shell == "pwsh" || shell == "powershell" {
+		return len(lower) > 1 && strings.HasPrefix("-command", lower)
+	}
+
+	return lower == "--command" || lower == "--commands" || isShortFlags(arg) && strings.ContainsRune(arg[1:], 'c')
+}
+
+// Does a shell read its script from standard-input? It does with -s, or when it isn't given an inline script
+// or a script file
+func readsStdinScript(shell string, args []AuditWord) bool {
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+
+		switch {
+		case !arg.literal:
+			return false
+		case isScriptFlag(shell, arg.text):
+			return false
+		case isShortFlags(arg.text) && strings.ContainsRune(arg.text, 's'):
+			return true
+		case arg.text == "-o" || arg.text == "-O" || arg.text == "+o" || arg.text == "+O":
+			// set an option named by the next argument
+			idx++
+		case strings.HasPrefix(arg.text, "-") || strings.HasPrefix(arg.text, "+"):
+		default:
+			// a script file
+			return false
+		}
+	}
+
+	return true
+}
+
+// Check how a shell's inline script uses user-input; whether the input is the script, or how the script uses
+// it. The script is run with rl's environment-variables, and its arguments as positional-parameters. Words
+// are numbered from offset
+func (names InputNames) auditShellScript(shell string, args []AuditWord, offset int) []wordFinding {
+	findings := []wordFinding{}
+
+	flagIdx := -1
+	for idx, arg := range args {
+		if arg.literal && isScriptFlag(shell, arg.text) {
+			flagIdx = idx
+			break
+		}
+	}
+
+	if flagIdx == -1 {
+		return findings
+	}
+
+	// the script is the first argument after the flag that isn't another flag
+	scriptIdx := flagIdx + 1
+	for scriptIdx < len(args) && args[scriptIdx].literal && strings.HasPrefix(args[scriptIdx].text, "-") {
+		scriptIdx++
+	}
+
+	if scriptIdx == len(args) {
+		return findings
+	}
+
+	script := args[scriptIdx]
+	runner := "'" + shell + " " + args[flagIdx].text + "'"
+
+	if script.input != "" {
+		return append(findings, wordFinding{offset + scriptIdx, script.input + " is evaluated as code by " + runner})
+	}
+
+	if !script.literal {
+		return findings
+	}
+
+	nested, err := AnalyseCommand(script.text, FindShellProfile(shell), names.withPositional(args[scriptIdx+1:]))
+
+	if err != nil {
+		return append(findings, wordFinding{offset + scriptIdx, fmt.Sprintf("the script run by %v could not be parsed to check it is safe: %v", runner, err)})
+	}
+
+	for _, finding := range nested {
+		findings = append(findings, wordFinding{offset + scriptIdx, "in the script run by " + runner + ", " + finding.String()})
+	}
+
+	return findings
+}
+
+// Check how a command's words use user-input: as the command-name, evaluated as code by eval or a shell, or as
+// an argument to a destructive command. Wrappers like sudo or xargs are looked past to find what's really run
+func (names InputNames) auditWords(words []AuditWord) []wordFinding {
+	findings := []wordFinding{}
+
+	nameIdx := commandWord(words)
+	if nameIdx == -1 {
+		return findings
+	}
+
+	if input := words[nameIdx].input; input != "" {
+		return append(findings, wordFinding{nameIdx, input + " is used as a command-name, so any program typed will be run"})
+	}
+
+	name := filepath.Base(words[nameIdx].text)
+
+	switch {
+	case EVALUATING_COMMANDS[name]:
+		for idx := nameIdx + 1; idx < len(words); idx++ {
+			if input := words[idx].input; input != "" {
+				findings = append(findings, wordFinding{idx, input + " is evaluated as code by '" + name + "'"})
+			}
+		}
+	case EVALUATING_SHELLS[name]:
+		findings = append(findings, names.auditShellScript(name, words[nameIdx+1:], nameIdx+1)...)
+	case DESTRUCTIVE_COMMANDS[name]:
+		for idx := nameIdx + 1; idx < len(words); idx++ {
+			if input := words[idx].input; input != "" {
+				findings = append(findings, wordFinding{idx, input + " is an argument to '" + name + "', which can destroy data"})
+			}
+		}
+	}
+
+	return findings
+}
+
+// Reduce a parsed command's arguments to AuditWords, with the position of each word's user-input
+func (names InputNames) callWords(call *syntax.CallExpr) ([]AuditWord, []syntax.Pos) {
+	words := make([]AuditWord, len(call.Args))
+	positions := make([]syntax.Pos, len(call.Args))
+
+	for idx, arg := range call.Args {
+		words[idx], positions[idx] = names.auditWord(arg)
+	}
+
+	return words, positions
+}
+
+// Check how a simple command uses user-input
+func (names InputNames) auditCall(call *syntax.CallExpr) []AuditFinding {
+	findings := []AuditFinding{}
+
+	for _, arg := range call.Args {
+		for _, ref := range names.findUnquotedInput(arg) {
+			findings = append(findings, unquotedFinding(ref))
+		}
+	}
+
+	words, positions := names.callWords(call)
+
+	for _, found := range names.auditWords(words) {
+		findings = append(findings, findingAt(positions[found.word], found.reason))
+	}
+
+	return findings
+}
+
+// Find the shell a command runs, if it's a shell that reads its script from standard-input. Returns the
+// shell's name, or an empty string
+func stdinShell(call *syntax.CallExpr) string {
+	words, _ := InputNames{}.callWords(call)
+
+	nameIdx := commandWord(words)
+	if nameIdx == -1 {
+		return ""
+	}
+
+	name := filepath.Base(words[nameIdx].text)
+	if !EVALUATING_SHELLS[name] || !readsStdinScript(name, words[nameIdx+1:]) {
+		return ""
+	}
+
+	return name
+}
+
+// Check whether user-input is piped into a shell, which runs it as code; e.g echo "$RL_INPUT" | sh
+func (names InputNames) auditPipe(pipe *syntax.BinaryCmd) []AuditFinding {
+	findings := []AuditFinding{}
+
+	if pipe.Op != syntax.Pipe && pipe.Op != syntax.PipeAll {
+		return findings
+	}
+
+	call, ok := pipe.Y.Cmd.(*syntax.CallExpr)
+	if !ok {
+		return findings
+	}
+
+	if shell := stdinShell(call); shell != "" {
+		if ref, ok := names.findInputExpansion(pipe.X); ok {
+			findings = append(findings, findingAt(ref.pos, "$"+ref.name+" is piped into '"+shell+"', which runs it as code"))
+		}
+	}
+
+	return findings
+}
+
+// Check whether user-input is fed to a shell by a here-document or here-string, which runs it as code
+func (names InputNames) auditStmt(stmt *syntax.Stmt) []AuditFinding {
+	findings := []AuditFinding{}
+
+	call, ok := stmt.Cmd.(*syntax.CallExpr)
+	if !ok {
+		return findings
+	}
+
+	shell := stdinShell(call)
+	if shell == "" {
+		return findings
+	}
+
+	for _, redirect := range stmt.Redirs {
+		if !FEEDING_REDIRECTS[redirect.Op] {
+			continue
+		}
+
+		text := redirect.Word
+		if redirect.Hdoc != nil {
+			text = redirect.Hdoc
+		}
+
+		if ref, ok := names.findInputExpansion(text); ok {
+			findings = append(findings, findingAt(ref.pos, "$"+ref.name+" is fed into '"+shell+"' by '"+redirect.Op.String()+"', which runs it as code"))
+		}
+	}
+
+	return findings
+}
+
+// Check whether user-input names a file that is written to
+func (names InputNames) auditRedirect(redirect *syntax.Redirect) []AuditFinding {
+	findings := []AuditFinding{}
 
-	command = [a-zA-Z-_0-9]+
+	if redirect.Word == nil || !WRITING_REDIRECTS[redirect.Op] {
+		return findings
+	}
+
+	for _, ref := range names.findUnquotedInput(redirect.Word) {
+		findings = append(findings, unquotedFinding(ref))
+	}
+
+	if ref, ok := names.findInputExpansion(redirect.Word); ok {
+		findings = append(findings, findingAt(ref.pos, "$"+ref.name+" names a file that is written to by '"+redirect.Op.String()+"'"))
+	}
+
+	return findings
+}
+
+// Find the arithmetic expressions directly within a node; these evaluate user-input as code
+func arithmExprs(node syntax.Node) []syntax.Node {
+	switch node := node.(type) {
+	case *syntax.ArithmExp:
+		return []syntax.Node{node.X}
+	case *syntax.ArithmCmd:
+		return []syntax.Node{node.X}
+	case *syntax.LetClause:
+		exprs := make([]syntax.Node, len(node.Exprs))
+		for idx, expr := range node.Exprs {
+			exprs[idx] = expr
+		}
+		return exprs
+	case *syntax.CStyleLoop:
+		exprs := []syntax.Node{}
+		for _, expr := range []syntax.ArithmExpr{node.Init, node.Cond, node.Post} {
+			if expr != nil {
+				exprs = append(exprs, expr)
+			}
+		}
+		return exprs
+	case *syntax.ParamExp:
+		exprs := []syntax.Node{}
+		if node.Index != nil {
+			exprs = append(exprs, node.Index)
+		}
+		if node.Slice != nil {
+			exprs = append(exprs, node.Slice.Offset, node.Slice.Length)
+		}
+		return exprs
+	case *syntax.BinaryTest:
+		if ARITHMETIC_TESTS[node.Op] {
+			return []syntax.Node{node.X, node.Y}
+		}
+	}
+
+	return nil
+}
+
+// Parse a command template into a shell syntax-tree, and find dangerous uses of $RL_INPUT, or the other
+// variables holding user-input:
+// - unquoted, where it's split into words and glob-expanded
+// - evaluated as code; by eval, in a script run by 'sh -c', piped into a shell, or as arithmetic
+// - used as a command-name, including behind wrappers like sudo, env, or xargs
+// - naming a file that is written to by a redirection
+// - as an argument to destructive commands like rm or dd
+//
+// Literal scripts run by 'sh -c' are checked in turn. This catches common mistakes, but it can't prove a
+// template is safe.
+func AnalyseTemplate(template string, names InputNames) ([]AuditFinding, error) {
+	parser := syntax.NewParser(syntax.Variant(syntax.LangBash))
+	file, err := parser.Parse(strings.NewReader(template), "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []AuditFinding{}
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch node := node.(type) {
+		case *syntax.Stmt:
+			findings = append(findings, names.auditStmt(node)...)
+		case *syntax.CallExpr:
+			findings = append(findings, names.auditCall(node)...)
+		case *syntax.BinaryCmd:
+			findings = append(findings, names.auditPipe(node)...)
+		case *syntax.Redirect:
+			findings = append(findings, names.auditRedirect(node)...)
+		}
+
+		for _, expr := range arithmExprs(node) {
+			for _, ref := range names.findArithmInput(expr) {
+				findings = append(findings, arithmFinding(ref))
+			}
+		}
+
+		return true
+	})
+
+	return findings, nil
+}
+
+// Only templates for shells rl knows can be checked. Others are refused, rather than run unchecked, unless the
+// user accepts the risk with --danger-zone
+func CheckAuditable(profile ShellProfile) error {
+	if profile.audit != NoAudit {
+		return nil
+	}
+
+	return fmt.Errorf("%v isn't a shell rl knows, so its commands can't be checked for dangerous uses of user-input. Use --shell with one of sh, bash, zsh, ksh, dash, fish, nu, or pwsh, or run with --danger-zone to skip this check", profile.name)
+}
+
+// Check a template for dangerous uses of user-input, in the way its shell's templates are checked
+func AnalyseCommand(template string, profile ShellProfile, names InputNames) ([]AuditFinding, error) {
+	switch profile.audit {
+	case PosixAudit:
+		return AnalyseTemplate(template, names)
+	case WordAudit:
+		return AnalyseWords(template, profile, names), nil
+	}
+
+	return nil, CheckAuditable(profile)
+}
+
+// Check a template for dangerous uses of user-input, and summarise any problems as an error
+func AuditTemplate(command string, profile ShellProfile, names InputNames) error {
+	findings, err := AnalyseCommand(command, profile, names)
+
+	if err != nil {
+		return fmt.Errorf("could not parse the command to check it is safe: %v", err)
+	}
+
+	if len(findings) == 0 {
+		return nil
+	}
+
+	reasons := make([]string, len(findings))
+	for idx, finding := range findings {
+		reasons[idx] = finding.String()
+	}
+
+	return errors.New(strings.Join(reasons, "; "))
+}
+
+// Find dangerous uses of {} and input-field placeholders in an argv command run with --exec. There's no
+// shell to split or evaluate the input, so only the command-name, shells' -c scripts, and destructive commands are
+// checked, looking past wrappers like sudo or xargs as for templates
+func AnalyseArgv(argv []string, fields []string) []string {
+	placeholders := []string{ARGV_INPUT_PLACEHOLDER}
+	for _, field := range fields {
+		placeholders = append(placeholders, ArgvPlaceholder(field))
+	}
+
+	words := make([]AuditWord, len(argv))
+
+	for idx, arg := range argv {
+		words[idx] = AuditWord{text: arg, literal: true, prefix: arg}
+
+		// the input is whichever placeholder comes first in the argument
+		first := len(arg)
+		for _, placeholder := range placeholders {
+			if at := strings.Index(arg, placeholder); at != -1 && at < first {
+				first = at
+				words[idx] = AuditWord{prefix: arg[:at], input: placeholder}
+			}
+		}
+	}
+
+	reasons := []string{}
+
+	for _, found := range NewInputNames(fields).auditWords(words) {
+		reasons = append(reasons, fmt.Sprintf("argument %v: %v", found.word+1, found.reason))
+	}
+
+	return reasons
+}
 
-	subcommand = [a-zA-Z-_0-9]+
-	arguments = ["'][a-zA-Z-_0-9$]+["']
-	flag = [[-]{1,2}a-z+]
-	call = command [subcommand] [<argument...>] [<flag...>] [<argument...>]
-	combiners = <call> [&&|;|`||`] <call>
-	input = argument | call | combiners
+// Audit the argv command provided to rl --exec, and report any problems before rl exits
+func AuditArgvCommand(argv []string, fields []string) int {
+	reasons := AnalyseArgv(argv, fields)
 
-Todo
-*/
-func AuditCommand(command *string) int {
-	if strings.Trim(*command, "") == "$RL_INPUT" {
-		fmt.Printf("RL: do not use $RL_INPUT in unquoted format; it's dangerous.\n")
+	if len(reasons) == 0 {
+		return 0
+	}
+
+	fmt.Printf("RL: refusing to run this command, as it uses user-input dangerously:\n\n")
+	fmt.Printf("  %v\n\n", QuoteArgv(argv))
+
+	for _, reason := range reasons {
+		fmt.Printf("  %v\n", reason)
+	}
+
+	fmt.Printf("\nSee the \"Please Be Careful\" section of 'rl --help' for more information, or run with --danger-zone to skip these checks.\n")
+
+	return 1
+}
+
+// Audit the command provided to rl, and report any problems before rl exits
+func AuditCommand(command *string, profile ShellProfile, names InputNames) int {
+	findings, err := AnalyseCommand(*command, profile, names)
 
+	if err != nil {
+		fmt.Printf("RL: could not parse the command to check it is safe: %v. Run with --danger-zone to skip this check.\n", err)
 		return 1
 	}
 
-	return 0
+	if len(findings) == 0 {
+		return 0
+	}
+
+	fmt.Printf("RL: refusing to run this command, as it uses user-input dangerously:\n\n")
+
+	lines := strings.Split(*command, "\n")
+
+	for _, finding := range findings {
+		line := int(finding.line)
+		col := int(finding.col)
+
+		// show the line the problem is on, and point at the problem
+		if line >= 1 && line <= len(lines) && col >= 1 {
+			fmt.Printf("  %v\n", lines[line-1])
+			fmt.Printf("  %v^\n", strings.Repeat(" ", col-1))
+		}
+		fmt.Printf("  %v\n\n", finding)
+	}
+
+	fmt.Printf("See the \"Please Be Careful\" section of 'rl --help' for more information, or run with --danger-zone to skip these checks.\n")
+
+	return 1
 }
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyseTemplate(t *testing.T) {
+	cases := []struct {
+		template string
+		fields   []string
+		reason   string // part of the reason for one of the findings; empty when the template is safe
+	}{
+		// safe templates
+		{`grep "$RL_INPUT" file`, nil, ""},
+		{`rg --json -- "$RL_INPUT"`, nil, ""},
+		{`echo "$RL_INPUT" | grep foo`, nil, ""},
+		{`jq "$RL_INPUT" < data.json > out.json`, nil, ""},
+		{`sudo grep "$RL_INPUT" /var/log/syslog`, nil, ""},
+		{`nice -n 5 grep "$RL_INPUT" file`, nil, ""},
+		{`env FOO=bar grep "$RL_INPUT" file`, nil, ""},
+		{`xargs -I {} grep "$RL_INPUT" {}`, nil, ""},
+		{`timeout 5 grep "$RL_INPUT" file`, nil, ""},
+		{`sh -c 'grep "$RL_INPUT" file'`, nil, ""},
+		{`sh -c 'grep "$1" file' sh "$RL_INPUT"`, nil, ""},
+		{`bash -xc 'grep -- "$RL_INPUT" file'`, nil, ""},
+		{`echo "$RL_INPUT" | sh -c 'grep foo'`, nil, ""},
+		{`echo "$RL_INPUT" | bash script.sh`, nil, ""},
+		{`echo $(( 1 + 2 )) "$RL_INPUT"`, nil, ""},
+		{`rm -f /tmp/rl-cache; grep "$RL_INPUT" file`, nil, ""},
+		{`rg "$PATTERN" -g "$GLOB"`, []string{"PATTERN", "GLOB"}, ""},
+
+		// unquoted
+		{`grep $RL_INPUT file`, nil, "is unquoted"},
+		{`rg "$PATTERN" -g $GLOB`, []string{"PATTERN", "GLOB"}, "$GLOB is unquoted"},
+
+		// evaluated as code
+		{`eval "$RL_INPUT"`, nil, "evaluated as code by 'eval'"},
+		{`source "$RL_INPUT"`, nil, "evaluated as code by 'source'"},
+		{`sh -c "$RL_INPUT"`, nil, "evaluated as code by 'sh -c'"},
+		{`bash -xc "$RL_INPUT"`, nil, "evaluated as code by 'bash -xc'"},
+		{`bash -e -c "$RL_INPUT"`, nil, "evaluated as code by 'bash -c'"},
+		{`bash -o pipefail -c "grep $RL_INPUT"`, nil, "evaluated as code by 'bash -c'"},
+		{`sudo sh -c "$RL_INPUT"`, nil, "evaluated as code by 'sh -c'"},
+		{`sh -c '$RL_INPUT'`, nil, "in the script run by 'sh -c', 1:1: $RL_INPUT is unquoted"},
+		{`bash -c 'eval "$RL_INPUT"'`, nil, "in the script run by 'bash -c', 1:7: $RL_INPUT is evaluated as code by 'eval'"},
+		{`sh -c 'eval "$1"' sh "$RL_INPUT"`, nil, "$1 is evaluated as code by 'eval'"},
+		{`sh -c 'sh -c "$RL_INPUT"'`, nil, "evaluated as code by 'sh -c'"},
+		{`echo "$RL_INPUT" | sh`, nil, "piped into 'sh'"},
+		{`printf '%s\n' "$RL_INPUT" | grep . | bash -s`, nil, "piped into 'bash'"},
+		{`sh <<< "$RL_INPUT"`, nil, "fed into 'sh' by '<<<'"},
+		{"bash <<EOF\n$RL_INPUT\nEOF", nil, "fed into 'bash' by '<<'"},
+		{`echo $(( RL_INPUT + 1 ))`, nil, "evaluated as an arithmetic expression"},
+		{`echo "$(( $RL_INPUT ))"`, nil, "evaluated as an arithmetic expression"},
+		{`(( count = RL_INPUT ))`, nil, "evaluated as an arithmetic expression"},
+		{`let "count = RL_INPUT"`, nil, "evaluated as an arithmetic expression"},
+		{`[[ 1 -eq "$RL_INPUT" ]]`, nil, "evaluated as an arithmetic expression"},
+		{`echo "${lines[RL_INPUT]}"`, nil, "evaluated as an arithmetic expression"},
+
+		// used as a command-name
+		{`"$RL_INPUT" --help`, nil, "used as a command-name"},
+		{`sudo "$RL_INPUT"`, nil, "used as a command-name"},
+		{`env "$RL_INPUT"`, nil, "used as a command-name"},
+		{`env FOO=bar "$RL_INPUT"`, nil, "used as a command-name"},
+		{`xargs "$RL_INPUT"`, nil, "used as a command-name"},
+		{`xargs -0 "$RL_INPUT"`, nil, "used as a command-name"},
+		{`nice -n 5 "$RL_INPUT"`, nil, "used as a command-name"},
+		{`timeout 5 "$RL_INPUT"`, nil, "used as a command-name"},
+		{`"$PATTERN" file`, []string{"PATTERN"}, "$PATTERN is used as a command-name"},
+
+		// writes to a file
+		{`echo hello > "$RL_INPUT"`, nil, "names a file that is written to by '>'"},
+		{`echo hello >> "$RL_INPUT"`, nil, "names a file that is written to by '>>'"},
+
+		// destructive commands, including behind wrappers
+		{`rm -rf "$RL_INPUT"`, nil, "an argument to 'rm'"},
+		{`dd if=/dev/zero of="$RL_INPUT"`, nil, "an argument to 'dd'"},
+		{`sudo -u root rm "$RL_INPUT"`, nil, "an argument to 'rm'"},
+		{`sudo -Eu root chmod 777 "$RL_INPUT"`, nil, "an argument to 'chmod'"},
+		{`nice -n 5 rm "$RL_INPUT"`, nil, "an argument to 'rm'"},
+		{`env -u HOME truncate -s 0 "$RL_INPUT"`, nil, "an argument to 'truncate'"},
+		{`timeout -s KILL 5 mv "$RL_INPUT" /tmp`, nil, "an argument to 'mv'"},
+		{`/bin/rm "$RL_INPUT"`, nil, "an argument to 'rm'"},
+		{`'rm' "$RL_INPUT"`, nil, "an argument to 'rm'"},
+	}
+
+	for _, testCase := range cases {
+		findings, err := AnalyseTemplate(testCase.template, NewInputNames(testCase.fields))
+
+		if err != nil {
+			t.Errorf("%q: failed to parse: %v", testCase.template, err)
+			continue
+		}
+
+		if testCase.reason == "" {
+			if len(findings) != 0 {
+				t.Errorf("%q: expected no findings, got %v", testCase.template, findings)
+			}
+			continue
+		}
+
+		if len(findings) == 0 {
+			t.Errorf("%q: expected a finding containing %q, got none", testCase.template, testCase.reason)
+			continue
+		}
+
+		found := false
+		for _, finding := range findings {
+			found = found || strings.Contains(finding.String(), testCase.reason)
+		}
+
+		if !found {
+			t.Errorf("%q: expected a finding containing %q, got %v", testCase.template, testCase.reason, findings)
+		}
+	}
+}
+
+func TestAnalyseTemplateParseError(t *testing.T) {
+	if _, err := AnalyseTemplate(`grep "$RL_INPUT`, NewInputNames(nil)); err == nil {
+		t.Errorf("expected an unterminated quote to fail to parse")
+	}
+
+	findings, err := AnalyseTemplate(`sh -c 'if'`, NewInputNames(nil))
+	if err != nil || len(findings) != 1 || !strings.Contains(findings[0].reason, "could not be parsed") {
+		t.Errorf("expected an unparseable 'sh -c' script to be a finding, got %v, %v", findings, err)
+	}
+}
+
+func TestAnalyseArgv(t *testing.T) {
+	cases := []struct {
+		argv   []string
+		fields []string
+		reason string // part of one of the reasons; empty when the command is safe
+	}{
+		{[]string{"rg", "--json", "{}"}, nil, ""},
+		{[]string{"grep", "-e", "{}", "file"}, nil, ""},
+		{[]string{"sudo", "grep", "{}", "/var/log/syslog"}, nil, ""},
+		{[]string{"xargs", "-0", "grep", "{}"}, nil, ""},
+		{[]string{"sh", "-c", `grep "$1" file`, "sh", "{}"}, nil, ""},
+		{[]string{"rg", "{PATTERN}", "-g", "{GLOB}"}, []string{"PATTERN", "GLOB"}, ""},
+		{[]string{"echo", "{NOT_A_FIELD}"}, []string{"PATTERN"}, ""},
+
+		{[]string{"{}"}, nil, "argument 1: {} is used as a command-name"},
+		{[]string{"env", "{}"}, nil, "argument 2: {} is used as a command-name"},
+		{[]string{"sudo", "{}"}, nil, "argument 2: {} is used as a command-name"},
+		{[]string{"xargs", "-0", "{}"}, nil, "argument 3: {} is used as a command-name"},
+		{[]string{"nice", "-n", "5", "./{}"}, nil, "argument 4: {} is used as a command-name"},
+		{[]string{"{PATTERN}", "file"}, []string{"PATTERN"}, "argument 1: {PATTERN} is used as a command-name"},
+		{[]string{"sh", "-c", "{}"}, nil, "argument 3: {} is evaluated as code by 'sh -c'"},
+		{[]string{"sh", "-xc", "grep {}"}, nil, "argument 3: {} is evaluated as code by 'sh -xc'"},
+		{[]string{"bash", "-c", `eval "$RL_INPUT"`}, nil, "argument 3: in the script run by 'bash -c'"},
+		{[]string{"sh", "-c", `eval "$1"`, "sh", "{}"}, nil, "$1 is evaluated as code by 'eval'"},
+		{[]string{"rm", "-rf", "{}"}, nil, "argument 3: {} is an argument to 'rm'"},
+		{[]string{"sudo", "-u", "root", "rm", "{GLOB}"}, []string{"GLOB"}, "argument 5: {GLOB} is an argument to 'rm'"},
+	}
+
+	for _, testCase := range cases {
+		reasons := AnalyseArgv(testCase.argv, testCase.fields)
+
+		if testCase.reason == "" {
+			if len(reasons) != 0 {
+				t.Errorf("%q: expected no reasons, got %v", testCase.argv, reasons)
+			}
+			continue
+		}
+
+		found := false
+		for _, reason := range reasons {
+			found = found || strings.Contains(reason, testCase.reason)
+		}
+
+		if !found {
+			t.Errorf("%q: expected a reason containing %q, got %v", testCase.argv, testCase.reason, reasons)
+		}
+	}
+}
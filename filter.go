@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/smallnest/ringbuffer"
+)
+
+// A line of standard-input matching the filter query
+type FilterMatch struct {
+	line      string // the matching line
+	score     int    // how well the line matched; higher is better
+	positions []int  // the matched rune-positions in the line
+}
+
+// The lines matching the filter, shown with the matched characters highlighted
+type MatchedLines []FilterMatch
+
+func (matches MatchedLines) Len() int {
+	return len(matches)
+}
+
+func (matches MatchedLines) Plain(idx int) string {
+	return matches[idx].line
+}
+
+func (matches MatchedLines) Styled(idx int) string {
+	return HighlightPositions(matches[idx].line, matches[idx].positions, "yellow")
+}
+
+// Split buffered standard-input into lines. A trailing newline doesn't start another line
+func SplitLines(data []byte) []string {
+	text := strings.TrimSuffix(string(data), "\n")
+
+	if text == "" {
+		return []string{}
+	}
+
+	return strings.Split(text, "\n")
+}
+
+// Compile a filter regular-expression. Like fuzzy-matching, it's case-insensitive unless
+// the query contains an upper-case character
+func compileFilterRegex(query string) (*regexp.Regexp, error) {
+	if strings.IndexFunc(query, unicode.IsUpper) == -1 {
+		query = "(?i)" + query
+	}
+
+	return regexp.Compile(query)
+}
+
+// Convert the byte-ranges a regular-expression matched into rune-positions
+func regexPositions(line string, ranges [][]int) []int {
+	positions := []int{}
+
+	for _, match := range ranges {
+		start := utf8.RuneCountInString(line[:match[0]])
+		count := utf8.RuneCountInString(line[match[0]:match[1]])
+
+		for offset := 0; offset < count; offset++ {
+			positions = append(positions, start+offset)
+		}
+	}
+
+	return positions
+}
+
+// Match lines against a query. Fuzzy matches are ranked best-first; regular-expression
+// matches, and equally good fuzzy matches, keep their input order. An empty query matches everything
+func FilterLines(lines []string, query string, useRegex bool) ([]FilterMatch, error) {
+	matches := []FilterMatch{}
+
+	if useRegex {
+		pattern, err := compileFilterRegex(query)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range lines {
+			if ranges := pattern.FindAllStringIndex(line, -1); ranges != nil {
+				matches = append(matches, FilterMatch{line, 0, regexPositions(line, ranges)})
+			}
+		}
+
+		return matches, nil
+	}
+
+	for _, line := range lines {
+		if score, positions, ok := FuzzyMatch(query, line); ok {
+			matches = append(matches, FilterMatch{line, score, positions})
+		}
+	}
+
+	sort.SliceStable(matches, func(left, right int) bool {
+		return matches[left].score > matches[right].score
+	})
+
+	return matches, nil
+}
+
+// Standard-input split into lines for filter mode. It's split as it arrives, rather than on each
+// keystroke, and filtered off the UI's goroutine
+type StdinFilter struct {
+	stdin  *ringbuffer.RingBuffer
+	lock   sync.Mutex // guards lines and read; filters run concurrently with the refresh
+	lines  []string   // each line read so far, including a final line without a trailing newline
+	ended  bool       // does the last line read end with a newline?
+	read   int        // how many bytes of standard-input have been split
+	latest int32      // counts the filters started; only the latest one's matches are shown
+	done   int32      // set once the input is done, so the refresh stops
+}
+
+func NewStdinFilter(stdin *ringbuffer.RingBuffer) *StdinFilter {
+	return &StdinFilter{stdin: stdin, lines: []string{}, ended: true}
+}
+
+// The lines of standard-input, splitting any that arrived since they were last read. A final
+// line without a trailing newline is split again once more arrives; the lines are copied rather
+// than changed then, as earlier filters may still be reading them
+func (filter *StdinFilter) Lines() []string {
+	filter.lock.Lock()
+	defer filter.lock.Unlock()
+
+	if filter.stdin.Length() != filter.read {
+		data := filter.stdin.Bytes()
+		start := filter.read
+
+		lines := filter.lines
+		if !filter.ended {
+			last := len(lines) - 1
+			start -= len(lines[last])
+			lines = append([]string{}, lines[:last]...)
+		}
+
+		// unlike SplitLines, a lone newline is an empty line; it follows the lines already split
+		if chunk := string(data[start:]); chunk != "" {
+			lines = append(lines, strings.Split(strings.TrimSuffix(chunk, "\n"), "\n")...)
+		}
+
+		filter.lines = lines
+		filter.ended = len(data) == 0 || data[len(data)-1] == '\n'
+		filter.read = len(data)
+	}
+
+	return filter.lines[:len(filter.lines):len(filter.lines)]
+}
+
+// Filter standard-input with the current input, and show the matching lines. Filtering runs off the
+// UI's goroutine, and only the latest filter's matches are shown. When the input is done, print the
+// best match and exit
+func (tui *TUI) RunFilter() {
+	filter := tui.filter
+
+	// the best match is already being printed
+	if atomic.LoadInt32(&filter.done) == 1 {
+		return
+	}
+
+	run := atomic.AddInt32(&filter.latest, 1)
+	query := tui.state.lineBuffer.content
+	useRegex := tui.ctx.filter == FILTER_REGEX
+	done := tui.GetDone()
+
+	if done {
+		atomic.StoreInt32(&filter.done, 1)
+	}
+
+	tui.UpdatePreview()
+
+	go func() {
+		start := time.Now()
+		lines := filter.Lines()
+		matches, err := FilterLines(lines, query, useRegex)
+		elapsed := time.Now().Sub(start)
+
+		tui.app.tview.QueueUpdateDraw(func() {
+			if atomic.LoadInt32(&filter.latest) != run {
+				return
+			}
+
+			if done {
+				tui.ExitFilter(matches, err)
+			} else {
+				tui.ShowMatches(matches, len(lines), err, elapsed)
+			}
+		})
+	}()
+}
+
+// Print the best match and exit; exit with 1 if nothing matched
+func (tui *TUI) ExitFilter(matches []FilterMatch, err error) {
+	tui.Stop()
+
+	code := 1
+	if err == nil && len(matches) > 0 {
+		fmt.Println(matches[0].line)
+		code = 0
+	}
+
+	go func(exitChan chan int) {
+		exitChan <- code
+	}(tui.chans.exitCode)
+}
+
+// Show the lines matching the filter, and how many lines matched
+func (tui *TUI) ShowMatches(matches []FilterMatch, total int, err error, elapsed time.Duration) {
+	if err != nil {
+		// keep showing the last matches while the expression is being typed
+		tui.SetStderr([]byte(err.Error() + "\n"))
+		tui.latency.tview.SetText("[red]invalid regex[-:-:-]")
+		return
+	}
+	tui.SetStderr(nil)
+
+	shown := matches
+	if len(shown) > FILTER_MAX_LINES {
+		shown = shown[:FILTER_MAX_LINES]
+	}
+
+	tui.SetOutputLines(MatchedLines(shown))
+
+	if len(shown) == 0 {
+		tui.stdoutViewer.tview.SetText("")
+	} else {
+		tui.RenderOutput()
+	}
+
+	tui.linePosition.lineCount = len(shown)
+	tui.UpdateScrollPosition()
+
+	tui.latency.tview.SetText(fmt.Sprintf("[green]%v/%v[-:-:-] %vms", len(matches), total, elapsed.Milliseconds()))
+}
+
+// Standard-input may still be arriving; re-filter as it grows, until the input is done
+func (tui *TUI) StartFilterRefresh() {
+	go func() {
+		length := -1
+
+		for range time.Tick(FILTER_REFRESH_MS * time.Millisecond) {
+			if atomic.LoadInt32(&tui.filter.done) == 1 {
+				return
+			}
+
+			if current := tui.ctx.stdin.Length(); current != length {
+				length = current
+
+				// the mode and input are only read on the UI's goroutine
+				tui.app.tview.QueueUpdateDraw(func() {
+					if tui.mode != HelpMode && !tui.GetDone() {
+						tui.RunFilter()
+					}
+				})
+			}
+		}
+	}()
+}
+
+// Check rl can filter standard-input, when no command was given
+func CheckFilterInput() int {
+	piped, err := StdinPiped()
+
+	if err != nil || !piped {
+		fmt.Println("RL: no command was given, so rl filters standard-input; pipe lines into rl, or give a command to run")
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/smallnest/ringbuffer"
+)
+
+func TestSplitLines(t *testing.T) {
+	cases := []struct {
+		data     string
+		expected []string
+	}{
+		{"", []string{}},
+		{"\n", []string{}},
+		{"a", []string{"a"}},
+		{"a\n", []string{"a"}},
+		{"a\nb", []string{"a", "b"}},
+		{"a\n\nb\n", []string{"a", "", "b"}},
+	}
+
+	for _, testCase := range cases {
+		if actual := SplitLines([]byte(testCase.data)); !reflect.DeepEqual(actual, testCase.expected) {
+			t.Errorf("%q: expected %q, got %q", testCase.data, testCase.expected, actual)
+		}
+	}
+}
+
+func TestFilterLines(t *testing.T) {
+	lines := []string{"main.go", "README.md", "go.mod", "magic.go", "Makefile"}
+
+	cases := []struct {
+		query    string
+		useRegex bool
+		expected []string
+	}{
+		{"", false, lines},
+		{"go", false, []string{"main.go", "go.mod", "magic.go"}},
+		{"MA", false, []string{}},
+		{"M", false, []string{"Makefile", "README.md"}},
+		{`\.go$`, true, []string{"main.go", "magic.go"}},
+		{"^m", true, []string{"main.go", "magic.go", "Makefile"}},
+		{"^M", true, []string{"Makefile"}},
+	}
+
+	for _, testCase := range cases {
+		matches, err := FilterLines(lines, testCase.query, testCase.useRegex)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", testCase.query, err)
+			continue
+		}
+
+		actual := []string{}
+		for _, match := range matches {
+			actual = append(actual, match.line)
+		}
+
+		if !reflect.DeepEqual(actual, testCase.expected) {
+			t.Errorf("%q: expected %q, got %q", testCase.query, testCase.expected, actual)
+		}
+	}
+
+	if _, err := FilterLines(lines, "(", true); err == nil {
+		t.Errorf("expected an invalid regular-expression to fail")
+	}
+}
+
+func TestStdinFilterLines(t *testing.T) {
+	stdin := ringbuffer.New(100)
+	filter := NewStdinFilter(stdin)
+
+	steps := []struct {
+		written  string
+		expected []string
+	}{
+		{"", []string{}},
+		{"one\ntw", []string{"one", "tw"}},
+		{"o\n", []string{"one", "two"}},
+		{"three", []string{"one", "two", "three"}},
+		{"\nfour\n", []string{"one", "two", "three", "four"}},
+		{"\n", []string{"one", "two", "three", "four", ""}},
+	}
+
+	for _, step := range steps {
+		stdin.Write([]byte(step.written))
+
+		if actual := filter.Lines(); !reflect.DeepEqual(actual, step.expected) {
+			t.Errorf("after writing %q: expected %q, got %q", step.written, step.expected, actual)
+		}
+	}
+}
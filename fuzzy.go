@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/rivo/tview"
+)
+
+// Is the character at this position the start of a word?
+func fuzzyBoundary(text []rune, idx int) bool {
+	if idx == 0 {
+		return true
+	}
+
+	prev := text[idx-1]
+	return !unicode.IsLetter(prev) && !unicode.IsDigit(prev)
+}
+
+// Match a pattern against text as a subsequence, in the style of fzf. Matching is case-insensitive
+// unless the pattern contains an upper-case character. Returns a score, where higher is a better
+// match, the rune-indices of the matched characters in text, and whether the text matched at all
+func FuzzyMatch(pattern string, text string) (int, []int, bool) {
+	patternRunes := []rune(pattern)
+	textRunes := []rune(text)
+
+	if len(patternRunes) == 0 {
+		return 0, []int{}, true
+	}
+
+	caseSensitive := false
+	for _, char := range patternRunes {
+		if unicode.IsUpper(char) {
+			caseSensitive = true
+			break
+		}
+	}
+
+	equal := func(left rune, right rune) bool {
+		if caseSensitive {
+			return left == right
+		}
+		return unicode.ToLower(left) == unicode.ToLower(right)
+	}
+
+	// find the earliest position the whole pattern has matched by
+	patternIdx := 0
+	end := -1
+	for idx, char := range textRunes {
+		if equal(char, patternRunes[patternIdx]) {
+			patternIdx++
+
+			if patternIdx == len(patternRunes) {
+				end = idx
+				break
+			}
+		}
+	}
+
+	if end == -1 {
+		return 0, nil, false
+	}
+
+	// then walk backwards to find the tightest match ending there
+	positions := make([]int, len(patternRunes))
+	patternIdx = len(patternRunes) - 1
+	for idx := end; idx >= 0 && patternIdx >= 0; idx-- {
+		if equal(textRunes[idx], patternRunes[patternIdx]) {
+			positions[patternIdx] = idx
+			patternIdx--
+		}
+	}
+
+	score := 0
+	for idx, pos := range positions {
+		score += FUZZY_SCORE_MATCH
+
+		if fuzzyBoundary(textRunes, pos) {
+			score += FUZZY_BONUS_BOUNDARY
+		}
+
+		if idx > 0 {
+			gap := pos - positions[idx-1] - 1
+
+			if gap == 0 {
+				score += FUZZY_BONUS_CONSECUTIVE
+			} else {
+				score -= FUZZY_PENALTY_GAP_START + gap*FUZZY_PENALTY_GAP
+			}
+		}
+	}
+
+	return score, positions, true
+}
+
+// Wrap the characters at the given rune-positions in a tview colour tag. The
+// text is escaped, so any tags the text already contains are displayed literally
+func HighlightPositions(text string, positions []int, color string) string {
+	marked := map[int]bool{}
+	for _, pos := range positions {
+		marked[pos] = true
+	}
+
+	var highlighted strings.Builder
+	runes := []rune(text)
+
+	// write runs of matched or unmatched characters, escaping each run as a whole
+	for start := 0; start < len(runes); {
+		end := start
+		for end < len(runes) && marked[end] == marked[start] {
+			end++
+		}
+
+		segment := tview.Escape(string(runes[start:end]))
+
+		if marked[start] {
+			highlighted.WriteString("[" + color + "]" + segment + "[-]")
+		} else {
+			highlighted.WriteString(segment)
+		}
+
+		start = end
+	}
+
+	return highlighted.String()
+}
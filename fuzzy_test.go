@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	cases := []struct {
+		pattern   string
+		text      string
+		ok        bool
+		positions []int
+	}{
+		{"", "anything", true, []int{}},
+		{"abc", "abc", true, []int{0, 1, 2}},
+		{"abc", "a-b-c", true, []int{0, 2, 4}},
+		{"abc", "ABC", true, []int{0, 1, 2}},
+		{"ABC", "abc", false, nil},
+		{"Abc", "xAbc", true, []int{1, 2, 3}},
+		{"acb", "abc", false, nil},
+		{"abc", "ab", false, nil},
+		{"mgo", "main.go", true, []int{0, 5, 6}},
+		{"ég", "éclair.go", true, []int{0, 7}},
+	}
+
+	for _, testCase := range cases {
+		_, positions, ok := FuzzyMatch(testCase.pattern, testCase.text)
+
+		if ok != testCase.ok {
+			t.Errorf("%q in %q: expected ok to be %v", testCase.pattern, testCase.text, testCase.ok)
+			continue
+		}
+
+		if !reflect.DeepEqual(positions, testCase.positions) {
+			t.Errorf("%q in %q: expected positions %v, got %v", testCase.pattern, testCase.text, testCase.positions, positions)
+		}
+	}
+}
+
+func TestFuzzyMatchScore(t *testing.T) {
+	// each pair is a better match, then a worse one
+	cases := [][]string{
+		{"go", "main.go", "gxxxxo"},
+		{"fb", "foo_bar", "fxxxxb"},
+		{"abc", "abc", "axbxc"},
+	}
+
+	for _, testCase := range cases {
+		better, _, _ := FuzzyMatch(testCase[0], testCase[1])
+		worse, _, _ := FuzzyMatch(testCase[0], testCase[2])
+
+		if better <= worse {
+			t.Errorf("%q: expected %q (%v) to score higher than %q (%v)", testCase[0], testCase[1], better, testCase[2], worse)
+		}
+	}
+}
@@ -16,5 +16,5 @@ require (
 	golang.org/x/sys v0.0.0-20210921065528-437939a70204 // indirect
 	gopkg.in/yaml.v2 v2.4.0
 	mvdan.cc/sh v2.6.4+incompatible
-	mvdan.cc/sh/v3 v3.3.1 // indirect
+	mvdan.cc/sh/v3 v3.3.1
 )
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Finds the current input in output lines, so it can be highlighted
+type InputHighlighter struct {
+	literal string         // the input, matched literally
+	pattern *regexp.Regexp // the input, matched as a regular-expression; nil when matching literally
+}
+
+// Check the highlight_input configuration is a known way of matching the input
+func CheckHighlightMode(mode string) error {
+	switch mode {
+	case "", HIGHLIGHT_OFF, HIGHLIGHT_LITERAL, HIGHLIGHT_REGEX:
+		return nil
+	default:
+		return errors.New("highlight_input must be one of off, literal, or regex, but was '" + mode + "'")
+	}
+}
+
+// A highlighter for the current input, or nil when the input isn't being highlighted.
+// An input that isn't yet a valid regular-expression isn't highlighted
+func (tui *TUI) NewInputHighlighter() *InputHighlighter {
+	content := tui.state.lineBuffer.content
+
+	switch tui.stdoutViewer.highlightInput {
+	case HIGHLIGHT_LITERAL:
+		if content != "" {
+			return &InputHighlighter{content, nil}
+		}
+	case HIGHLIGHT_REGEX:
+		if pattern, err := compileFilterRegex(content); err == nil && content != "" {
+			return &InputHighlighter{content, pattern}
+		}
+	}
+
+	return nil
+}
+
+// Highlight each occurrence of the input in a line formatted for tview, keeping the line's colours
+func (hl *InputHighlighter) Highlight(line string) string {
+	segments := splitStyled(line)
+	visible := visibleText(segments)
+	ranges := []TextRange{}
+
+	if hl.pattern == nil {
+		width := utf8.RuneCountInString(hl.literal)
+
+		for _, start := range FindOccurrences(visible, hl.literal) {
+			ranges = append(ranges, TextRange{start, start + width})
+		}
+	} else {
+		text := string(visible)
+
+		for _, match := range hl.pattern.FindAllStringIndex(text, -1) {
+			start := utf8.RuneCountInString(text[:match[0]])
+			ranges = append(ranges, TextRange{start, start + utf8.RuneCountInString(text[match[0]:match[1]])})
+		}
+	}
+
+	return HighlightRanges(segments, ranges, func(nth int) string {
+		return INPUT_HIGHLIGHT_TAG
+	})
+}
+
+// :highlight literal|regex|off highlights the current input in the output. With no
+// argument, highlighting is toggled on or off
+func RunHighlightCommand(tui *TUI, args string) error {
+	mode := args
+
+	if mode == "" {
+		mode = HIGHLIGHT_OFF
+
+		if tui.stdoutViewer.highlightInput == HIGHLIGHT_OFF {
+			mode = tui.cfg.Config.HighlightInput
+			if mode == "" || mode == HIGHLIGHT_OFF {
+				mode = HIGHLIGHT_LITERAL
+			}
+		}
+	}
+
+	if err := CheckHighlightMode(mode); err != nil {
+		return errors.New("expected literal, regex, or off")
+	}
+
+	tui.stdoutViewer.highlightInput = mode
+	tui.FinishInternalCommand("highlight " + mode)
+	tui.RenderOutput()
+
+	return nil
+}
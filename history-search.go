@@ -0,0 +1,157 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// An overlay for searching previous inputs, like readline's reverse-search
+type TUIHistorySearch struct {
+	tview   *tview.Flex
+	input   *tview.InputField
+	results *tview.Table
+	hits    []HistoryHit
+	draft   string // the input before searching started; restored if the search is cancelled
+	open    bool
+}
+
+// Show the hits for the current query
+func (search *TUIHistorySearch) SetHits(hits []HistoryHit) {
+	search.hits = hits
+	search.results.Clear()
+
+	for row, hit := range hits {
+		input := HighlightPositions(hit.hist.Input, hit.positions, "yellow")
+		when := hit.hist.Time.Format(HISTORY_TIME_FORMAT)
+
+		search.results.SetCell(row, 0, tview.NewTableCell(input).SetExpansion(1))
+		search.results.SetCell(row, 1, tview.NewTableCell("[gray]"+when+"[-]"))
+		search.results.SetCell(row, 2, tview.NewTableCell("[blue]"+tview.Escape(hit.hist.Template)+"[-]").SetMaxWidth(HISTORY_SEARCH_TEMPLATE_WIDTH))
+	}
+}
+
+// Move the selection up or down the hits, without wrapping around
+func (search *TUIHistorySearch) MoveSelection(offset int) {
+	if len(search.hits) == 0 {
+		return
+	}
+
+	row, _ := search.results.GetSelection()
+	row += offset
+
+	if row < 0 {
+		row = 0
+	} else if row >= len(search.hits) {
+		row = len(search.hits) - 1
+	}
+
+	search.results.Select(row, 0)
+}
+
+// Open the history-search overlay, remembering what the user had typed
+func (tui *TUI) OpenHistorySearch() {
+	search := tui.historySearch
+
+	search.open = true
+	search.draft = tui.commandInput.tview.GetText()
+	search.input.SetText("")
+
+	tui.helpBar.tview.SetText(HELP_SEARCH)
+	tui.pages.ShowPage(PAGE_HISTORY_SEARCH)
+	tui.app.tview.SetFocus(search.input)
+}
+
+// Close the history-search overlay. If cancelled, or closed with no hit to choose, the user's
+// original input is restored
+func (tui *TUI) CloseHistorySearch(cancelled bool) {
+	search := tui.historySearch
+	row, _ := search.results.GetSelection()
+	chosen := !cancelled && row >= 0 && row < len(search.hits)
+
+	search.open = false
+	tui.pages.HidePage(PAGE_HISTORY_SEARCH)
+
+	// hits are previewed without being recorded; only the one chosen is saved to history. The draft
+	// was never changed by the search, so it isn't recorded again
+	if chosen {
+		tui.RecordInput()
+	} else {
+		tui.SetHistoryInput(search.draft)
+	}
+
+	tui.history.Reset()
+	tui.helpBar.tview.SetText(tui.keys.HelpBar(EditMode))
+	tui.SetInputFocus()
+}
+
+// Create the history-search overlay. Selecting a hit loads its input into the
+// prompt, which re-runs the command with that input
+func NewHistorySearch(tui *TUI) *TUIHistorySearch {
+	search := &TUIHistorySearch{}
+
+	results := tview.NewTable().
+		SetSelectable(true, false)
+
+	results.SetSelectionChangedFunc(func(row, column int) {
+		if search.open && row >= 0 && row < len(search.hits) {
+			tui.SetHistoryInput(search.hits[row].hist.Input)
+		}
+	})
+
+	onChange := func(query string) {
+		search.SetHits(SearchHistory(tui.history.entries, query))
+
+		if len(search.hits) > 0 {
+			results.Select(0, 0)
+			results.ScrollToBeginning()
+		}
+	}
+
+	onInput := func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyDown, tcell.KeyCtrlR:
+			search.MoveSelection(1)
+			return nil
+		case tcell.KeyUp, tcell.KeyCtrlS:
+			search.MoveSelection(-1)
+			return nil
+		case tcell.KeyEnter:
+			tui.CloseHistorySearch(false)
+			return nil
+		case tcell.KeyEscape:
+			tui.CloseHistorySearch(true)
+			return nil
+		}
+
+		return event
+	}
+
+	input := tview.NewInputField().
+		SetLabel(PROMPT_SEARCH).
+		SetLabelColor(tcell.ColorYellow).
+		SetChangedFunc(onChange)
+	input.SetInputCapture(onInput)
+
+	frame := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(results, 0, 1, false).
+		AddItem(input, 1, 0, true)
+	frame.
+		SetBorder(true).
+		SetTitle(" history ")
+
+	// centre the search box over the rest of rl
+	search.tview = tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().
+			SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(frame, 0, 6, true).
+			AddItem(nil, 0, 1, false), 0, 8, true).
+		AddItem(nil, 0, 1, false)
+
+	search.input = input
+	search.results = results
+
+	return search
+}
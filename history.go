@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Read every entry from the JSONL history file. Lines that cannot be parsed are skipped
+// rather than failing; a partially written line shouldn't make the history unusable
+func ReadHistory(historyPath string) ([]History, error) {
+	conn, err := os.Open(historyPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		conn.Close()
+	}()
+
+	entries := []History{}
+	scanner := bufio.NewScanner(conn)
+	// history lines contain the whole command, so allow for long lines
+	scanner.Buffer(make([]byte, 0, 64*1024), HISTORY_MAX_LINE_SIZE)
+
+	for scanner.Scan() {
+		var hist History
+		if err := json.Unmarshal(scanner.Bytes(), &hist); err != nil {
+			continue
+		}
+
+		entries = append(entries, hist)
+	}
+
+	return entries, scanner.Err()
+}
+
+// Group history entries into sessions by their start-time, and return the
+// last entry of each session; the last thing the user had typed before exiting.
+// Sessions are returned most-recent first
+func LastSessionEntries(entries []History) []History {
+	sessions := []History{}
+	seen := map[int64]int{}
+
+	for _, hist := range entries {
+		id := hist.StartTime.UnixNano()
+
+		if idx, ok := seen[id]; ok {
+			sessions[idx] = hist
+		} else {
+			seen[id] = len(sessions)
+			sessions = append(sessions, hist)
+		}
+	}
+
+	// reverse, so the most recent session comes first
+	for left, right := 0, len(sessions)-1; left < right; left, right = left+1, right-1 {
+		sessions[left], sessions[right] = sessions[right], sessions[left]
+	}
+
+	return sessions
+}
+
+// Find the history entry to rerun. An index of 1 is the most recent session,
+// 2 the session before that, and so on
+func RerunHistory(cfg *ConfigOpts, index int) (History, error) {
+	if !cfg.Config.SaveHistory {
+		return History{}, fmt.Errorf("history is disabled; set save_history to true in %v to use --rerun", cfg.ConfigPath)
+	}
+
+	if index < 1 {
+		return History{}, errors.New("the rerun index must be 1 or greater")
+	}
+
+	entries, err := ReadHistory(cfg.HistoryPath)
+	if err != nil {
+		return History{}, err
+	}
+
+	sessions := LastSessionEntries(entries)
+
+	if len(sessions) == 0 {
+		return History{}, fmt.Errorf("no history found in %v", cfg.HistoryPath)
+	}
+
+	if index > len(sessions) {
+		return History{}, fmt.Errorf("only %v sessions are saved in history, cannot rerun session %v", len(sessions), index)
+	}
+
+	return sessions[index-1], nil
+}
+
+// Create a history cursor for a template. The history file is indexed straight away
+func NewHistoryCursor(historyPath string, template string) HistoryCursor {
+	curs := HistoryCursor{
+		historyPath: historyPath,
+		template:    template,
+		index:       -1,
+	}
+	curs.Load()
+
+	return curs
+}
+
+// Read the history file into memory. A missing or unreadable history file
+// just means there's nothing to navigate
+func (curs *HistoryCursor) Load() {
+	if curs.loaded {
+		return
+	}
+
+	entries, _ := ReadHistory(curs.historyPath)
+	curs.entries = append(entries, curs.entries...)
+	curs.loaded = true
+}
+
+// Add an entry from the current session to the in-memory index
+func (curs *HistoryCursor) Record(hist History) {
+	curs.entries = append(curs.entries, hist)
+}
+
+// Get the number of history entries indexed
+func (curs *HistoryCursor) GetCount() int {
+	return len(curs.entries)
+}
+
+// Get the history entry at a position in the current navigation, where zero is
+// the most recent input
+func (curs *HistoryCursor) GetHistory(index int) History {
+	if index < 0 || index >= len(curs.matches) {
+		return History{}
+	}
+
+	return curs.matches[index]
+}
+
+// Is an entry an input the user settled on, rather than a keystroke on the way
+// to one? History is written on every change, so "f", "fo", "foo" are all saved; only
+// inputs that weren't extended by the next or previous entry in their session are kept
+func settledInput(entries []History, idx int) bool {
+	curr := entries[idx]
+
+	if curr.Input == "" {
+		return false
+	}
+
+	isExtension := func(other History) bool {
+		return other.StartTime.Equal(curr.StartTime) &&
+			len(other.Input) > len(curr.Input) &&
+			strings.HasPrefix(other.Input, curr.Input)
+	}
+
+	if idx > 0 && isExtension(entries[idx-1]) {
+		return false
+	}
+	if idx < len(entries)-1 && isExtension(entries[idx+1]) {
+		return false
+	}
+
+	return true
+}
+
+// Compute the inputs to navigate through, most-recent first. Inputs for the current
+// template come first, followed by inputs from every other template
+func (curs *HistoryCursor) findMatches() []History {
+	sameTemplate := []History{}
+	otherTemplates := []History{}
+	seen := map[string]bool{curs.draft: true}
+
+	for idx := len(curs.entries) - 1; idx >= 0; idx-- {
+		hist := curs.entries[idx]
+
+		if seen[hist.Input] || !settledInput(curs.entries, idx) {
+			continue
+		}
+		seen[hist.Input] = true
+
+		if hist.Template == curs.template {
+			sameTemplate = append(sameTemplate, hist)
+		} else {
+			otherTemplates = append(otherTemplates, hist)
+		}
+	}
+
+	return append(sameTemplate, otherTemplates...)
+}
+
+// Move one entry back in history. The first step back stores the current text
+// as a draft, so it can be restored later. Returns false if there's nowhere to move
+func (curs *HistoryCursor) Back(current string) (string, bool) {
+	if curs.index == -1 {
+		curs.draft = current
+		curs.matches = curs.findMatches()
+	}
+
+	if curs.index+1 >= len(curs.matches) {
+		return "", false
+	}
+
+	curs.index++
+	return curs.GetHistory(curs.index).Input, true
+}
+
+// Move one entry forward in history; moving past the most recent entry
+// restores the user's draft. Returns false if there's nowhere to move
+func (curs *HistoryCursor) Forward() (string, bool) {
+	if curs.index == -1 {
+		return "", false
+	}
+
+	curs.index--
+
+	if curs.index == -1 {
+		return curs.draft, true
+	}
+
+	return curs.GetHistory(curs.index).Input, true
+}
+
+// Is the input one scrolled to in history, rather than the user's own draft?
+func (curs *HistoryCursor) Navigated() bool {
+	return curs.index != -1
+}
+
+// Stop navigating; called when the user edits the input themselves
+func (curs *HistoryCursor) Reset() {
+	curs.index = -1
+	curs.matches = nil
+}
+
+// A history entry matching a search query
+type HistoryHit struct {
+	hist      History // the matching history entry
+	score     int     // how well the entry matched; higher is better
+	positions []int   // the matched rune-positions in the input, if the input was the best-matching field
+}
+
+// Fuzzy-search settled inputs by their input, command, and template. Each input and template
+// pair is returned once, best match first, and most-recent first for equally good matches
+func SearchHistory(entries []History, query string) []HistoryHit {
+	hits := []HistoryHit{}
+	seen := map[string]bool{}
+
+	for idx := len(entries) - 1; idx >= 0; idx-- {
+		hist := entries[idx]
+		key := hist.Template + "\x00" + hist.Input
+
+		if seen[key] || !settledInput(entries, idx) {
+			continue
+		}
+		seen[key] = true
+
+		hit := HistoryHit{hist: hist}
+		matched := false
+
+		if score, positions, ok := FuzzyMatch(query, hist.Input); ok {
+			hit.score, hit.positions, matched = score, positions, true
+		}
+
+		for _, field := range []string{hist.Command, hist.Template} {
+			if score, _, ok := FuzzyMatch(query, field); ok && (!matched || score > hit.score) {
+				hit.score, hit.positions, matched = score, nil, true
+			}
+		}
+
+		if matched {
+			hits = append(hits, hit)
+		}
+	}
+
+	sort.SliceStable(hits, func(left, right int) bool {
+		return hits[left].score > hits[right].score
+	})
+
+	if len(hits) > HISTORY_SEARCH_MAX_HITS {
+		hits = hits[:HISTORY_SEARCH_MAX_HITS]
+	}
+
+	return hits
+}
+
+// Append a named session to the saved-sessions file
+func SaveSession(savedPath string, session SavedSession) error {
+	conn, err := os.OpenFile(savedPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, USER_READ_WRITE_OCTAL)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		conn.Close()
+	}()
+
+	entry, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.WriteString(string(entry) + "\n")
+	return err
+}
+
+// Find the most recently saved session with a name
+func FindSavedSession(savedPath string, name string) (SavedSession, error) {
+	conn, err := os.Open(savedPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return SavedSession{}, fmt.Errorf("no session named '%v' has been saved", name)
+	} else if err != nil {
+		return SavedSession{}, err
+	}
+	defer func() {
+		conn.Close()
+	}()
+
+	var found *SavedSession
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), HISTORY_MAX_LINE_SIZE)
+
+	for scanner.Scan() {
+		var session SavedSession
+		if err := json.Unmarshal(scanner.Bytes(), &session); err != nil {
+			continue
+		}
+
+		if session.Name == name {
+			found = &session
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return SavedSession{}, err
+	}
+
+	if found == nil {
+		return SavedSession{}, fmt.Errorf("no session named '%v' has been saved", name)
+	}
+
+	return *found, nil
+}
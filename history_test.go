@@ -0,0 +1,100 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// History entries typed in one session, a keystroke at a time
+func typedHistory(start time.Time, template string, inputs ...string) []History {
+	entries := []History{}
+	for _, input := range inputs {
+		entries = append(entries, History{Input: input, Template: template, StartTime: start})
+	}
+
+	return entries
+}
+
+func TestSettledInput(t *testing.T) {
+	first := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	entries := typedHistory(first, "grep", "f", "fo", "foo", "", "b", "ba", "bar", "b")
+	entries = append(entries, typedHistory(second, "grep", "barn", "x")...)
+
+	cases := []struct {
+		idx     int
+		settled bool
+	}{
+		{0, false}, // extended by "fo"
+		{1, false}, // extended by "foo"
+		{2, true},  // "foo" was cleared, rather than extended
+		{3, false}, // empty inputs are never settled
+		{4, false},
+		{6, true},  // "bar" was shortened to "b" after it
+		{7, false}, // "b" is a keystroke deleting "bar"
+		{8, true},  // "barn" extends "b", but in a later session
+		{9, true},
+	}
+
+	for _, testCase := range cases {
+		if actual := settledInput(entries, testCase.idx); actual != testCase.settled {
+			t.Errorf("%q (entry %v): expected settled to be %v", entries[testCase.idx].Input, testCase.idx, testCase.settled)
+		}
+	}
+}
+
+func TestHistoryCursor(t *testing.T) {
+	start := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	curs := NewHistoryCursor(filepath.Join(t.TempDir(), "missing"), "grep")
+	for _, hist := range typedHistory(start, "grep", "f", "fo", "foo", "", "bar") {
+		curs.Record(hist)
+	}
+	for _, hist := range typedHistory(start, "rg", "baz") {
+		curs.Record(hist)
+	}
+	for _, hist := range typedHistory(start.Add(time.Hour), "grep", "foo") {
+		curs.Record(hist)
+	}
+
+	if curs.Navigated() {
+		t.Errorf("expected a new cursor not to be navigating")
+	}
+
+	// inputs for the current template come first, most recent first, without repeats or the draft
+	expected := []string{"foo", "bar", "baz"}
+	for _, input := range expected {
+		actual, ok := curs.Back("draft")
+		if !ok || actual != input {
+			t.Errorf("expected back to move to %q, got %q, %v", input, actual, ok)
+		}
+	}
+
+	if _, ok := curs.Back("draft"); ok {
+		t.Errorf("expected back to stop at the oldest input")
+	}
+
+	for _, input := range []string{"bar", "foo", "draft"} {
+		actual, ok := curs.Forward()
+		if !ok || actual != input {
+			t.Errorf("expected forward to move to %q, got %q, %v", input, actual, ok)
+		}
+	}
+
+	if _, ok := curs.Forward(); ok || curs.Navigated() {
+		t.Errorf("expected forward to stop at the draft")
+	}
+
+	// the draft isn't offered as a history entry
+	curs.Back("bar")
+	if actual, _ := curs.Back("bar"); actual != "baz" {
+		t.Errorf("expected the draft to be skipped, got %q", actual)
+	}
+
+	curs.Reset()
+	if curs.Navigated() {
+		t.Errorf("expected reset to stop navigating")
+	}
+}
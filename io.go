@@ -5,9 +5,13 @@ import (
 	"bytes"
 	"io"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"syscall"
 
+	"github.com/rivo/tview"
 	"github.com/smallnest/ringbuffer"
 )
 
@@ -48,23 +52,308 @@ func StdinReader(input *ringbuffer.RingBuffer) {
 	}
 }
 
-// Substitute user-input into a command in place of the environment name;
-// useful to visualise what was run by the user
-func SubstitueCommand(execute *string, input *string) string {
-	return strings.ReplaceAll(*execute, "$"+ENVAR_NAME_RL_INPUT, *input)
+// Substitute user-input into a command in place of the environment name, written in the shell's
+// syntax (e.g $RL_INPUT, or $env.RL_INPUT in nushell); useful to visualise what was run by the user
+func SubstitueCommand(execute *string, input *string, inputVar string) string {
+	return strings.ReplaceAll(*execute, inputVar, *input)
 }
 
-// Get line-count in bytes.Buffer
-func LineCounter(content *bytes.Buffer) int {
-	count := 0
+// The placeholder for an input field in an argv command, e.g {PATTERN}
+func ArgvPlaceholder(field string) string {
+	return "{" + field + "}"
+}
 
-	for {
-		_, err := content.ReadBytes('\n')
+// Substitute user-input into each argument of an argv command in place of {}, and each input field in place
+// of its placeholder, e.g {PATTERN}. However the input is written, it stays within the argument it was
+// substituted into, and isn't substituted again
+func SubstituteArgv(argv []string, buffer *LineBuffer, fields []string) []string {
+	pairs := []string{ARGV_INPUT_PLACEHOLDER, buffer.content}
+	for idx, field := range fields {
+		pairs = append(pairs, ArgvPlaceholder(field), buffer.fields[idx])
+	}
+
+	replacer := strings.NewReplacer(pairs...)
+	substituted := make([]string, len(argv))
+
+	for idx, arg := range argv {
+		substituted[idx] = replacer.Replace(arg)
+	}
+
+	return substituted
+}
+
+// Join an argv command into a string for display, single-quoting arguments the shell would split or expand
+func QuoteArgv(argv []string) string {
+	quoted := make([]string, len(argv))
 
-		if err == nil {
-			count += 1
+	for idx, arg := range argv {
+		safe := arg != "" && strings.IndexFunc(arg, func(char rune) bool {
+			return !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./{}-", char)
+		}) == -1
+
+		if safe {
+			quoted[idx] = arg
 		} else {
-			return count
+			quoted[idx] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// Show the command that runs for the user's input; the shell template with $RL_INPUT and any
+// input fields substituted, or the argv command with {} substituted
+func (ctx *LineChangeCtx) CommandFor(buffer *LineBuffer) string {
+	if ctx.argv != nil {
+		return QuoteArgv(SubstituteArgv(ctx.argv, buffer, ctx.fields))
+	}
+
+	command := SubstitueCommand(ctx.execute, &buffer.content, ctx.profile.Var(ENVAR_NAME_RL_INPUT))
+
+	for _, idx := range LongestFirst(ctx.fields) {
+		command = SubstitueCommand(&command, &buffer.fields[idx], ctx.profile.Var(ctx.fields[idx]))
+	}
+
+	return command
+}
+
+// How the command refers to each input field in the user's shell, e.g $PATTERN
+func (ctx *LineChangeCtx) FieldVars() []string {
+	vars := make([]string, len(ctx.fields))
+	for idx, name := range ctx.fields {
+		vars[idx] = ctx.profile.Var(name)
+	}
+
+	return vars
+}
+
+// The indices of some names, longest name first; so $PATTERN is substituted before $PAT
+func LongestFirst(names []string) []int {
+	indices := make([]int, len(names))
+	for idx := range indices {
+		indices[idx] = idx
+	}
+
+	sort.SliceStable(indices, func(left, right int) bool {
+		return len(names[indices[left]]) > len(names[indices[right]])
+	})
+
+	return indices
+}
+
+// The text in the command that's replaced with the user's input
+func (ctx *LineChangeCtx) Placeholder() string {
+	if ctx.argv != nil {
+		return ARGV_INPUT_PLACEHOLDER
+	}
+
+	return ctx.profile.Var(ENVAR_NAME_RL_INPUT)
+}
+
+// Counts the lines and bytes of output as it's written, so the line-count is known while a
+// command is still running. A final line without a trailing newline is counted too
+type CountingWriter struct {
+	lock    sync.Mutex
+	lines   int  // the number of newline-terminated lines written
+	bytes   int  // the number of bytes written
+	partial bool // does the output written so far end part-way through a line?
+}
+
+func (counter *CountingWriter) Write(data []byte) (int, error) {
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+
+	counter.lines += bytes.Count(data, []byte{'\n'})
+	counter.bytes += len(data)
+
+	if len(data) > 0 {
+		counter.partial = data[len(data)-1] != '\n'
+	}
+
+	return len(data), nil
+}
+
+// The number of lines written, including a final unterminated line
+func (counter *CountingWriter) Lines() int {
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+
+	if counter.partial {
+		return counter.lines + 1
+	}
+
+	return counter.lines
+}
+
+// The number of bytes written
+func (counter *CountingWriter) Bytes() int {
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+
+	return counter.bytes
+}
+
+// Convert the "\r\n" line-endings a terminal writes back to "\n", so output read
+// from a pseudo-terminal looks like output read from a pipe
+type NewlineWriter struct {
+	writer    io.Writer
+	pendingCR bool // did the last write end in "\r"? It might be the start of "\r\n"
+}
+
+func (tgt *NewlineWriter) Write(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	converted := make([]byte, 0, len(data)+1)
+
+	if tgt.pendingCR && data[0] != '\n' {
+		converted = append(converted, '\r')
+	}
+	tgt.pendingCR = false
+
+	for idx, char := range data {
+		if char == '\r' {
+			if idx == len(data)-1 {
+				tgt.pendingCR = true
+				continue
+			}
+			if data[idx+1] == '\n' {
+				continue
+			}
+		}
+
+		converted = append(converted, char)
+	}
+
+	if _, err := tgt.writer.Write(converted); err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+func NewNewlineWriter(writer io.Writer) *NewlineWriter {
+	return &NewlineWriter{writer: writer}
+}
+
+// Matches ANSI escape-sequences, like the colours commands write to a pseudo-terminal
+var ANSI_PATTERN = regexp.MustCompile("\x1b\\[[0-9;?]*[ -/]*[@-~]|\x1b\\][^\x07]*\x07")
+
+// Remove ANSI escape-sequences from text, so chosen lines can be used by other commands
+func StripANSI(text string) string {
+	return ANSI_PATTERN.ReplaceAllString(text, "")
+}
+
+// Matches the ANSI escape-sequences that set colours and attributes
+var SGR_PATTERN = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// The SGR codes that set each attribute tview's translator writes, e.g b for bold
+var SGR_ATTRIBUTES = map[rune]string{'b': "1", 'd': "2", 'u': "4", 'l': "5"}
+
+// Follows the colours and attributes output sets a line at a time, so each line can be translated on
+// its own. Colours set on one line carry on to the next until they're reset, as they would when the
+// whole output is translated at once
+type ANSIStyles struct {
+	styles    io.Writer    // translates only the escape-sequences, so the tags they set can be read back
+	stylesOut bytes.Buffer // the tags set by the last line
+	fg        string       // the foreground colour at the end of the last line; empty for the default
+	bg        string       // the background colour at the end of the last line; empty for the default
+	attrs     string       // the attributes (bold, underline...) at the end of the last line; empty for none
+}
+
+func NewANSIStyles() *ANSIStyles {
+	styles := &ANSIStyles{}
+	styles.styles = tview.ANSIWriter(&styles.stylesOut)
+
+	return styles
+}
+
+// The tag restoring the colours and attributes the last line ended with; empty if it ended with none
+func (styles *ANSIStyles) Tag() string {
+	if styles.fg == "" && styles.bg == "" && styles.attrs == "" {
+		return ""
+	}
+
+	orReset := func(value string) string {
+		if value == "" {
+			return "-"
 		}
+		return value
+	}
+
+	return "[" + orReset(styles.fg) + ":" + orReset(styles.bg) + ":" + orReset(styles.attrs) + "]"
+}
+
+// Follow the colours and attributes set by a complete line
+func (styles *ANSIStyles) Advance(line string) {
+	for _, sequence := range SGR_PATTERN.FindAllString(line, -1) {
+		styles.styles.Write([]byte(sequence))
+	}
+
+	// the tags written are [-:-:-], [fg:bg], or [fg:bg:attributes]. Empty fields are unchanged, and - resets
+	for _, set := range strings.Split(styles.stylesOut.String(), "]") {
+		fields := strings.Split(strings.TrimPrefix(set, "["), ":")
+
+		if len(fields) < 2 {
+			continue
+		}
+
+		styles.fg = ApplyTagField(styles.fg, fields[0])
+		styles.bg = ApplyTagField(styles.bg, fields[1])
+
+		if len(fields) > 2 {
+			styles.attrs = ApplyTagField(styles.attrs, fields[2])
+		}
+	}
+	styles.stylesOut.Reset()
+}
+
+// Translate a line of output to tview's colour-tags, starting with the colours and attributes in a tag
+// from ANSIStyles. Text that looks like a tag is escaped, so it's drawn as the command wrote it
+func TranslateANSIFrom(tag string, line string) string {
+	var translated bytes.Buffer
+	writer := tview.ANSIWriter(&translated)
+
+	// tview's translator adds to the attributes it has seen, so it's started with the tag's
+	fields := strings.Split(strings.Trim(tag, "[]"), ":")
+	if len(fields) == 3 {
+		codes := []string{}
+		for _, attr := range fields[2] {
+			if code, ok := SGR_ATTRIBUTES[attr]; ok {
+				codes = append(codes, code)
+			}
+		}
+
+		if len(codes) > 0 {
+			writer.Write([]byte("\x1b[" + strings.Join(codes, ";") + "m"))
+			translated.Reset()
+		}
+	}
+
+	var escaped strings.Builder
+	last := 0
+
+	for _, loc := range ANSI_PATTERN.FindAllStringIndex(line, -1) {
+		escaped.WriteString(tview.Escape(line[last:loc[0]]))
+		escaped.WriteString(line[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	escaped.WriteString(tview.Escape(line[last:]))
+
+	writer.Write([]byte(escaped.String()))
+
+	return tag + translated.String()
+}
+
+// Apply a field from a colour-tag to the current value; empty fields leave it as it was, and - resets it
+func ApplyTagField(current string, field string) string {
+	switch field {
+	case "":
+		return current
+	case "-":
+		return ""
+	default:
+		return field
 	}
 }
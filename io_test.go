@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSubstituteArgv(t *testing.T) {
+	buffer := &LineBuffer{content: "foo", fields: []string{"foo", "*.go"}}
+	fields := []string{"PATTERN", "GLOB"}
+
+	got := SubstituteArgv([]string{"rg", "{}", "-g", "{GLOB}", "--", "{PATTERN}{}", "{OTHER}"}, buffer, fields)
+	want := []string{"rg", "foo", "-g", "*.go", "--", "foofoo", "{OTHER}"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// substituted input isn't substituted again
+	buffer = &LineBuffer{content: "{GLOB}", fields: []string{"{GLOB}", "{}"}}
+	got = SubstituteArgv([]string{"echo", "{}", "{GLOB}"}, buffer, fields)
+	want = []string{"echo", "{GLOB}", "{}"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	got = SubstituteArgv([]string{"grep", "{}"}, &LineBuffer{content: "a b"}, nil)
+	want = []string{"grep", "a b"}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestQuoteArgv(t *testing.T) {
+	cases := []struct {
+		argv     []string
+		expected string
+	}{
+		{[]string{"rg", "--json", "foo"}, `rg --json foo`},
+		{[]string{"grep", "a b"}, `grep 'a b'`},
+		{[]string{"echo", ""}, `echo ''`},
+		{[]string{"echo", "it's"}, `echo 'it'\''s'`},
+		{[]string{"echo", "$HOME", "*.go", "a;b"}, `echo '$HOME' '*.go' 'a;b'`},
+		{[]string{"rg", "-g", "src/**/{}.go", "key=value,x@y:1%+"}, `rg -g 'src/**/{}.go' key=value,x@y:1%+`},
+		{[]string{"echo", "tab\there", "new\nline"}, "echo 'tab\there' 'new\nline'"},
+		{[]string{}, ``},
+	}
+
+	for _, testCase := range cases {
+		if actual := QuoteArgv(testCase.argv); actual != testCase.expected {
+			t.Errorf("%q: expected %v, got %v", testCase.argv, testCase.expected, actual)
+		}
+	}
+}
+
+func TestANSIStyles(t *testing.T) {
+	styles := NewANSIStyles()
+	lines := []string{"\x1b[31mred", "still red", "\x1b[1mbold\x1b[0m", "plain"}
+	tags := []string{"", "[maroon:-:-]", "[maroon:-:-]", ""}
+
+	for idx, line := range lines {
+		if tag := styles.Tag(); tag != tags[idx] {
+			t.Errorf("line %v: expected it to start with %q, got %q", idx, tags[idx], tag)
+		}
+		styles.Advance(line)
+	}
+}
+
+func TestTranslateANSIFrom(t *testing.T) {
+	cases := []struct {
+		tag      string
+		line     string
+		expected string // the line's visible text
+	}{
+		{"", "plain", "plain"},
+		{"", "buffer.fields[idx]", "buffer.fields[idx]"},
+		{"", "\x1b[32m[green]\x1b[0m text", "[green] text"},
+		{"[red:-:-]", "still [red]", "still [red]"},
+	}
+
+	for _, testCase := range cases {
+		translated := TranslateANSIFrom(testCase.tag, testCase.line)
+
+		if !strings.HasPrefix(translated, testCase.tag) {
+			t.Errorf("%q: expected it to start with %q, got %q", testCase.line, testCase.tag, translated)
+		}
+
+		if visible := string(visibleText(splitStyled(translated))); visible != testCase.expected {
+			t.Errorf("%q: expected %q to be shown, got %q from %q", testCase.line, testCase.expected, visible, translated)
+		}
+	}
+
+	// attributes the tag sets carry on when the line adds a colour
+	if translated := TranslateANSIFrom("[-:-:b]", "\x1b[31mred"); !strings.Contains(translated, "[maroon::b]") {
+		t.Errorf("expected bold to carry on, got %q", translated)
+	}
+}
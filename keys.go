@@ -0,0 +1,563 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Something a key can be bound to in a mode
+type KeyAction struct {
+	name     string         // the name the keys configuration binds
+	defaults []string       // the chords bound to the action by default
+	hint     string         // how the help-bar describes the action; actions without one aren't shown there
+	about    string         // what the action does, for the documentation
+	run      func(tui *TUI) // run the action
+}
+
+// The chords bound to each action, by mode
+type KeyBindings map[PromptMode]map[string]string
+
+// Chords bound to action names, by mode name, as written in the keys configuration
+type KeysConfig map[string]map[string]string
+
+// The modes keys can be bound in, in the order they're documented
+var KEY_MODES = []PromptMode{EditMode, ViewMode, CommandMode, HelpMode}
+
+// The name the keys configuration uses for a mode
+func ModeName(mode PromptMode) string {
+	switch mode {
+	case EditMode:
+		return "edit"
+	case ViewMode:
+		return "view"
+	case CommandMode:
+		return "command"
+	default:
+		return "help"
+	}
+}
+
+// Exit rl without output
+func (tui *TUI) Quit() {
+	tui.Stop()
+	tui.chans.exitCode <- 0
+}
+
+// List the actions keys can be bound to in a mode
+func ModeActions(mode PromptMode) []KeyAction {
+	quit := KeyAction{"quit", []string{"ctrl-c"}, "", "exit without output", (*TUI).Quit}
+
+	switch mode {
+	case EditMode:
+		return []KeyAction{
+			{"view-mode", []string{"esc"}, "to switch to view mode", "switch to view mode", func(tui *TUI) {
+				tui.SetMode(ViewMode)
+			}},
+			{"run", []string{"enter"}, "to exit with command-output", "run the command a final time, print its output, and exit", (*TUI).RunFinalCommand},
+			{"next-field", []string{"tab"}, "", "with several input fields (-f), move to the next field", func(tui *TUI) {
+				tui.CycleField(1)
+			}},
+			{"previous-field", []string{"backtab"}, "", "with several input fields (-f), move to the previous field", func(tui *TUI) {
+				tui.CycleField(-1)
+			}},
+			{"history-back", []string{"up"}, "", "show the previous input from history", func(tui *TUI) {
+				if tui.focusedField == 0 {
+					tui.ScrollHistoryBack()
+				}
+			}},
+			{"history-forward", []string{"down"}, "", "show the next input from history, or what you were typing", func(tui *TUI) {
+				if tui.focusedField == 0 {
+					tui.ScrollHistoryForward()
+				}
+			}},
+			{"history-search", []string{"ctrl-r"}, "", "search history for a previous input", (*TUI).OpenHistorySearch},
+			quit,
+		}
+	case ViewMode:
+		return []KeyAction{
+			{"quit", []string{"esc", "q", "ctrl-c"}, "to quit", "exit without output", (*TUI).Quit},
+			{"edit-mode", []string{"/"}, "to edit input", "switch to edit mode", func(tui *TUI) {
+				tui.SetMode(EditMode)
+			}},
+			{"command-mode", []string{":"}, "for commands", "switch to command mode", func(tui *TUI) {
+				tui.SetMode(CommandMode)
+			}},
+			{"help-mode", []string{"?"}, "for help", "switch to help mode", func(tui *TUI) {
+				tui.SetMode(HelpMode)
+			}},
+			{"toggle-mark", []string{"space", "tab"}, "to mark lines", "mark or unmark the line under the cursor, and move to the next line", (*TUI).ToggleMark},
+			{"choose", []string{"enter"}, "", "print the marked lines and exit; with nothing marked, run the command and exit", func(tui *TUI) {
+				if tui.ChoosesOnEnter() {
+					tui.PrintChosen()
+				} else {
+					tui.RunFinalCommand()
+				}
+			}},
+			{"toggle-stderr", []string{"e"}, "", "show or hide the standard-error pane", (*TUI).ToggleStderr},
+			{"find", []string{"f"}, "", "search the output; opens command mode with 'find '", func(tui *TUI) {
+				tui.SetMode(CommandMode)
+				tui.commandInput.tview.SetText("find ")
+			}},
+			{"next-match", []string{"n"}, "", "move the cursor to the next search match", func(tui *TUI) {
+				tui.CycleMatch(1)
+			}},
+			{"previous-match", []string{"N"}, "", "move the cursor to the previous search match", func(tui *TUI) {
+				tui.CycleMatch(-1)
+			}},
+			{"yank", []string{"y"}, "", "copy the marked lines, or the line under the cursor, to the clipboard", func(tui *TUI) {
+				tui.Yank(false)
+			}},
+			{"yank-all", []string{"Y"}, "", "copy the whole output to the clipboard", func(tui *TUI) {
+				tui.Yank(true)
+			}},
+			{"up", []string{"up", "k"}, "", "move the cursor up", func(tui *TUI) {
+				count, _ := tui.TakeCount()
+				tui.MoveCursor(-count)
+			}},
+			{"down", []string{"down", "j"}, "", "move the cursor down", func(tui *TUI) {
+				count, _ := tui.TakeCount()
+				tui.MoveCursor(count)
+			}},
+			{"page-up", []string{"pgup", "ctrl-b"}, "", "move a page up", func(tui *TUI) {
+				count, _ := tui.TakeCount()
+				tui.MoveCursor(-tui.PageHeight() * count)
+			}},
+			{"page-down", []string{"pgdn", "ctrl-f"}, "", "move a page down", func(tui *TUI) {
+				count, _ := tui.TakeCount()
+				tui.MoveCursor(tui.PageHeight() * count)
+			}},
+			{"half-page-up", []string{"ctrl-u"}, "", "move half a page up", func(tui *TUI) {
+				count, _ := tui.TakeCount()
+				tui.MoveCursor(-tui.PageHeight() / 2 * count)
+			}},
+			{"half-page-down", []string{"ctrl-d"}, "", "move half a page down", func(tui *TUI) {
+				count, _ := tui.TakeCount()
+				tui.MoveCursor(tui.PageHeight() / 2 * count)
+			}},
+			{"first-line", []string{"home", "g"}, "", "move to the first line, or with a count, to that line", func(tui *TUI) {
+				// lines are one-indexed, like the line-position header
+				count, _ := tui.TakeCount()
+				tui.MoveCursorTo(count - 1)
+			}},
+			{"last-line", []string{"end", "G"}, "", "move to the last line, or with a count, to that line", func(tui *TUI) {
+				if count, counted := tui.TakeCount(); counted {
+					tui.MoveCursorTo(count - 1)
+				} else {
+					tui.MoveCursorTo(tui.stdoutViewer.selection.Len() - 1)
+				}
+			}},
+			{"scroll-left", []string{"left", "h"}, "", "scroll left, when wrapping is off", func(tui *TUI) {
+				count, _ := tui.TakeCount()
+				tui.ScrollColumns(-count)
+			}},
+			{"scroll-right", []string{"right", "l"}, "", "scroll right, when wrapping is off", func(tui *TUI) {
+				count, _ := tui.TakeCount()
+				tui.ScrollColumns(count)
+			}},
+		}
+	case CommandMode:
+		return []KeyAction{
+			{"run-command", []string{"enter"}, "to run a command", "run the command typed", func(tui *TUI) {
+				tui.RunInternalCommand(tui.commandInput.tview.GetText())
+			}},
+			{"complete", []string{"tab"}, "to complete a command-name", "complete the command-name being typed", (*TUI).CompleteInternalCommand},
+			{"view-mode", []string{"esc"}, "to switch to view mode", "return to view mode", func(tui *TUI) {
+				tui.SetMode(ViewMode)
+			}},
+			quit,
+		}
+	default:
+		return []KeyAction{
+			{"view-mode", []string{"esc", "q"}, "to quit", "return to view mode", func(tui *TUI) {
+				tui.SetMode(ViewMode)
+				tui.UpdateScrollPosition()
+			}},
+			{"edit-mode", []string{"/"}, "to switch to edit input", "switch to edit mode", func(tui *TUI) {
+				tui.SetMode(EditMode)
+			}},
+			{"command-mode", []string{":"}, "to enter commands", "switch to command mode", func(tui *TUI) {
+				tui.SetMode(CommandMode)
+			}},
+			quit,
+		}
+	}
+}
+
+// Find an action by name
+func FindKeyAction(mode PromptMode, name string) (KeyAction, bool) {
+	for _, action := range ModeActions(mode) {
+		if action.name == name {
+			return action, true
+		}
+	}
+
+	return KeyAction{}, false
+}
+
+// The names of each key tcell reports, like enter, pgdn, or ctrl-o
+func KeyNameSet() map[string]bool {
+	names := map[string]bool{"space": true}
+
+	for _, name := range tcell.KeyNames {
+		names[strings.ToLower(name)] = true
+	}
+
+	return names
+}
+
+// Write a chord with its modifiers in a fixed order, so each chord has one spelling
+func JoinChord(ctrl bool, alt bool, shift bool, base string) string {
+	chord := base
+
+	if shift {
+		chord = "shift-" + chord
+	}
+	if alt {
+		chord = "alt-" + chord
+	}
+	if ctrl {
+		chord = "ctrl-" + chord
+	}
+
+	return chord
+}
+
+// Parse a chord from the keys configuration, like ctrl-o, alt-x, pgdn, or G. Modifiers and key-names
+// ignore case; single characters don't, so g and G differ
+func ParseChord(chord string) (string, error) {
+	ctrl, alt, shift := false, false, false
+	base := chord
+
+	for {
+		lower := strings.ToLower(base)
+
+		if strings.HasPrefix(lower, "ctrl-") && len(base) > len("ctrl-") {
+			ctrl, base = true, base[len("ctrl-"):]
+		} else if strings.HasPrefix(lower, "alt-") && len(base) > len("alt-") {
+			alt, base = true, base[len("alt-"):]
+		} else if strings.HasPrefix(lower, "shift-") && len(base) > len("shift-") {
+			shift, base = true, base[len("shift-"):]
+		} else {
+			break
+		}
+	}
+
+	names := KeyNameSet()
+
+	if utf8.RuneCountInString(base) == 1 {
+		if shift {
+			return "", fmt.Errorf("'%v' isn't a key; write the shifted character itself, like 'G'", chord)
+		}
+
+		// only some characters can be typed with ctrl, like ctrl-o
+		if ctrl {
+			base = strings.ToLower(base)
+
+			if !names["ctrl-"+base] {
+				return "", fmt.Errorf("'%v' isn't a key", chord)
+			}
+		}
+
+		if base == " " {
+			base = "space"
+		}
+
+		return JoinChord(ctrl, alt, shift, base), nil
+	}
+
+	base = strings.ToLower(base)
+
+	if !names[base] || strings.HasPrefix(base, "ctrl-") {
+		return "", fmt.Errorf("'%v' isn't a key", chord)
+	}
+
+	return JoinChord(ctrl, alt, shift, base), nil
+}
+
+// The chord a key-press is bound with
+func ChordName(event *tcell.EventKey) string {
+	mods := event.Modifiers()
+	ctrl := mods&tcell.ModCtrl != 0
+	alt := mods&tcell.ModAlt != 0
+	shift := mods&tcell.ModShift != 0
+
+	if event.Key() == tcell.KeyRune {
+		base := string(event.Rune())
+		if base == " " {
+			base = "space"
+		}
+
+		// characters are named as typed, so shift is already part of them
+		return JoinChord(false, alt, false, base)
+	}
+
+	base, ok := tcell.KeyNames[event.Key()]
+	if !ok {
+		return ""
+	}
+
+	base = strings.ToLower(base)
+
+	if strings.HasPrefix(base, "ctrl-") {
+		ctrl, base = true, strings.TrimPrefix(base, "ctrl-")
+	}
+
+	return JoinChord(ctrl, alt, shift, base)
+}
+
+// Bind the default keys, then the keys from the keys configuration. A configured chord replaces
+// the default action for it; binding a chord to none unbinds it
+func NewKeyBindings(configured KeysConfig) (KeyBindings, error) {
+	bindings := KeyBindings{}
+
+	for _, mode := range KEY_MODES {
+		bindings[mode] = map[string]string{}
+
+		for _, action := range ModeActions(mode) {
+			for _, chord := range action.defaults {
+				bindings[mode][chord] = action.name
+			}
+		}
+	}
+
+	modeNames := []string{}
+	for name := range configured {
+		modeNames = append(modeNames, name)
+	}
+	sort.Strings(modeNames)
+
+	for _, modeName := range modeNames {
+		mode, ok := ParseModeName(modeName)
+		if !ok {
+			return nil, fmt.Errorf("keys: unknown mode '%v'; expected edit, view, command, or help", modeName)
+		}
+
+		chords := []string{}
+		for chord := range configured[modeName] {
+			chords = append(chords, chord)
+		}
+		sort.Strings(chords)
+
+		for _, chord := range chords {
+			name := configured[modeName][chord]
+
+			canonical, err := ParseChord(chord)
+			if err != nil {
+				return nil, fmt.Errorf("keys.%v: %v", modeName, err)
+			}
+
+			if err := CheckChordFor(mode, canonical); err != nil {
+				return nil, fmt.Errorf("keys.%v: %v", modeName, err)
+			}
+
+			if name == KEY_UNBOUND {
+				delete(bindings[mode], canonical)
+				continue
+			}
+
+			if _, ok := FindKeyAction(mode, name); !ok {
+				return nil, fmt.Errorf("keys.%v: '%v' isn't an action in %v mode; expected one of %v",
+					modeName, name, modeName, strings.Join(ActionNames(mode), ", "))
+			}
+
+			bindings[mode][canonical] = name
+		}
+	}
+
+	return bindings, nil
+}
+
+// Look up a mode by the name the keys configuration uses
+func ParseModeName(name string) (PromptMode, bool) {
+	for _, mode := range KEY_MODES {
+		if ModeName(mode) == name {
+			return mode, true
+		}
+	}
+
+	return EditMode, false
+}
+
+// The names of the actions in a mode
+func ActionNames(mode PromptMode) []string {
+	names := []string{}
+	for _, action := range ModeActions(mode) {
+		names = append(names, action.name)
+	}
+
+	return names
+}
+
+// Check a chord can be bound in a mode. Characters are typed into the input in edit and
+// command mode, and digits type a count in view mode
+func CheckChordFor(mode PromptMode, chord string) error {
+	single := utf8.RuneCountInString(chord) == 1 || chord == "space"
+
+	if (mode == EditMode || mode == CommandMode) && single {
+		return fmt.Errorf("'%v' can't be bound, as it's typed into the input", chord)
+	}
+
+	if mode == ViewMode && single && unicode.IsDigit([]rune(chord)[0]) {
+		return fmt.Errorf("'%v' can't be bound, as digits type a count", chord)
+	}
+
+	return nil
+}
+
+// The action bound to a chord in a mode
+func (keys KeyBindings) Action(mode PromptMode, chord string) (KeyAction, bool) {
+	name, ok := keys[mode][chord]
+	if !ok {
+		return KeyAction{}, false
+	}
+
+	return FindKeyAction(mode, name)
+}
+
+// The chords bound to an action; configured chords first, then the defaults in their usual order
+func (keys KeyBindings) Chords(mode PromptMode, action KeyAction) []string {
+	configured := []string{}
+	defaults := []string{}
+
+	for _, chord := range action.defaults {
+		if keys[mode][chord] == action.name {
+			defaults = append(defaults, chord)
+		}
+	}
+
+	for chord, name := range keys[mode] {
+		if name == action.name && !contains(action.defaults, chord) {
+			configured = append(configured, chord)
+		}
+	}
+	sort.Strings(configured)
+
+	return append(configured, defaults...)
+}
+
+func contains(items []string, item string) bool {
+	for _, candidate := range items {
+		if candidate == item {
+			return true
+		}
+	}
+
+	return false
+}
+
+// How a chord is written in the help-bar and documentation, e.g ESCAPE, CTRL-R, or q
+func DisplayChord(chord string) string {
+	if utf8.RuneCountInString(chord) == 1 {
+		return chord
+	}
+
+	if chord == "esc" {
+		return "ESCAPE"
+	}
+
+	return strings.ToUpper(chord)
+}
+
+// The help-bar text for a mode, naming the keys bound to its main actions. At most two keys are
+// shown for each action, to keep the help-bar on one line
+func (keys KeyBindings) HelpBar(mode PromptMode) string {
+	parts := []string{}
+
+	for _, action := range ModeActions(mode) {
+		chords := keys.Chords(mode, action)
+
+		if action.hint == "" || len(chords) == 0 {
+			continue
+		}
+
+		if len(chords) > HELP_BAR_MAX_KEYS {
+			chords = chords[:HELP_BAR_MAX_KEYS]
+		}
+
+		shown := []string{}
+		for _, chord := range chords {
+			shown = append(shown, "[green]"+DisplayChord(chord)+"[-:-:-]")
+		}
+
+		parts = append(parts, strings.Join(shown, " or ")+" "+action.hint)
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return "press " + strings.Join(parts, ", ")
+}
+
+// List the keys bound in each mode, for help-mode
+func (keys KeyBindings) Documentation() string {
+	var docs strings.Builder
+
+	docs.WriteString("\nKey Bindings\n=============\n\n")
+	docs.WriteString("  The keys in use. Change them in the keys section of ~/.config/rl.yaml; see 'Configuration'.\n")
+
+	for _, mode := range KEY_MODES {
+		name := ModeName(mode)
+		docs.WriteString("\n  " + strings.ToUpper(name[:1]) + name[1:] + "-Mode\n")
+
+		for _, action := range ModeActions(mode) {
+			shown := []string{}
+			for _, chord := range keys.Chords(mode, action) {
+				shown = append(shown, DisplayChord(chord))
+			}
+
+			if len(shown) == 0 {
+				shown = []string{"(unbound)"}
+			}
+
+			docs.WriteString(fmt.Sprintf("  - %-20s %-16s %v\n", strings.Join(shown, ", "), action.name, action.about))
+		}
+	}
+
+	return docs.String()
+}
+
+// The documentation shown in help-mode, with the keys currently bound
+func HelpDocumentation(keys KeyBindings) string {
+	return HelpIntroduction +
+		ModesDocumentation +
+		keys.Documentation() +
+		OutputDocumentation +
+		Configuration +
+		HistoryDocs +
+		EnvironmentalVariables +
+		PleaseBeCareful
+}
+
+// Run the action bound to a key-press in the current mode. Digits typed in view mode build a
+// count for the next action. Returns whether the key was used
+func (tui *TUI) HandleKey(event *tcell.EventKey) bool {
+	if tui.mode == ViewMode && tui.TakeDigit(event) {
+		return true
+	}
+
+	chord := ChordName(event)
+
+	// actions from the actions configuration only use keys view mode leaves free
+	if template, ok := tui.cfg.Config.Actions[chord]; ok && tui.mode == ViewMode {
+		tui.stdoutViewer.count = 0
+		tui.RunAction(chord, template)
+		return true
+	}
+
+	action, ok := tui.keys.Action(tui.mode, chord)
+	if !ok {
+		return false
+	}
+
+	action.run(tui)
+
+	// a count only applies to the key typed after it
+	tui.stdoutViewer.count = 0
+	return true
+}
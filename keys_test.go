@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParseChord(t *testing.T) {
+	cases := []struct {
+		chord    string
+		expected string // empty when the chord isn't a key
+	}{
+		{"ctrl-o", "ctrl-o"},
+		{"Ctrl-O", "ctrl-o"},
+		{"alt-x", "alt-x"},
+		{"ALT-X", "alt-X"},
+		{"alt-ctrl-o", "ctrl-alt-o"},
+		{"G", "G"},
+		{"g", "g"},
+		{":", ":"},
+		{" ", "space"},
+		{"space", "space"},
+		{"Enter", "enter"},
+		{"PgDn", "pgdn"},
+		{"shift-tab", "shift-tab"},
+		{"f1", "f1"},
+		{"shift-g", ""},
+		{"ctrl-1", ""},
+		{"ctrl-", ""},
+		{"nope", ""},
+		{"ctrl-enter", "ctrl-enter"},
+	}
+
+	for _, testCase := range cases {
+		actual, err := ParseChord(testCase.chord)
+
+		if testCase.expected == "" {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %q", testCase.chord, actual)
+			}
+			continue
+		}
+
+		if err != nil || actual != testCase.expected {
+			t.Errorf("%q: expected %q, got %q, %v", testCase.chord, testCase.expected, actual, err)
+		}
+	}
+}
+
+func TestChordName(t *testing.T) {
+	cases := []struct {
+		event    *tcell.EventKey
+		expected string
+	}{
+		{tcell.NewEventKey(tcell.KeyRune, 'g', tcell.ModNone), "g"},
+		{tcell.NewEventKey(tcell.KeyRune, 'G', tcell.ModShift), "G"},
+		{tcell.NewEventKey(tcell.KeyRune, ' ', tcell.ModNone), "space"},
+		{tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModAlt), "alt-x"},
+		{tcell.NewEventKey(tcell.KeyCtrlO, 0, tcell.ModCtrl), "ctrl-o"},
+		{tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone), "enter"},
+		{tcell.NewEventKey(tcell.KeyPgDn, 0, tcell.ModNone), "pgdn"},
+		{tcell.NewEventKey(tcell.KeyBacktab, 0, tcell.ModNone), "backtab"},
+		{tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModAlt), "alt-up"},
+	}
+
+	for _, testCase := range cases {
+		if actual := ChordName(testCase.event); actual != testCase.expected {
+			t.Errorf("%v: expected %q, got %q", testCase.event.Name(), testCase.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// The output line drawn on a screen-row of the stdout viewer, and whether there's a line there
+func (tui *TUI) LineAt(y int) (int, bool) {
+	_, top, _, _ := tui.stdoutViewer.tview.GetInnerRect()
+
+	first, last, ok := tui.VisibleLines()
+	if !ok || y < top {
+		return 0, false
+	}
+
+	row := top
+	for idx := first; idx <= last; idx++ {
+		row += tui.LineHeight(idx)
+
+		if y < row {
+			return idx, true
+		}
+	}
+
+	return 0, false
+}
+
+// Scroll the output by some lines, without moving the cursor
+func (tui *TUI) ScrollLines(offset int) {
+	selection := &tui.stdoutViewer.selection
+
+	if selection.Len() == 0 {
+		return
+	}
+
+	selection.top += offset
+
+	if lastTop := tui.LastTop(); selection.top > lastTop {
+		selection.top = lastTop
+	}
+	if selection.top < 0 {
+		selection.top = 0
+	}
+
+	tui.RenderOutput()
+	tui.UpdateScrollPosition()
+}
+
+// Handle the mouse. The wheel scrolls the output, clicking a line moves the cursor to it in view mode,
+// double-clicking marks it, and clicking an input edits it. Other mouse events are dropped, so clicks
+// can't focus something the current mode doesn't expect
+func (tui *TUI) HandleMouse(event *tcell.EventMouse, action tview.MouseAction) (*tcell.EventMouse, tview.MouseAction) {
+	// tview passes on the event returned for the previous action, so it's nil once one is dropped
+	if event == nil {
+		return nil, 0
+	}
+
+	// moving and releasing the mouse come before a click; dropping them would drop the click too
+	if action == tview.MouseMove || action == tview.MouseLeftUp {
+		return event, action
+	}
+
+	viewer := tui.stdoutViewer.tview
+	x, y := event.Position()
+
+	if tui.historySearch.open {
+		return nil, 0
+	}
+
+	// help is plain text, which the viewer scrolls itself
+	if tui.mode == HelpMode {
+		if viewer.InRect(x, y) && (action == tview.MouseScrollUp || action == tview.MouseScrollDown) {
+			return event, action
+		}
+
+		return nil, 0
+	}
+
+	if viewer.InRect(x, y) {
+		switch action {
+		case tview.MouseScrollUp:
+			tui.ScrollLines(-MOUSE_SCROLL_LINES)
+		case tview.MouseScrollDown:
+			tui.ScrollLines(MOUSE_SCROLL_LINES)
+		case tview.MouseLeftClick, tview.MouseLeftDoubleClick:
+			line, ok := tui.LineAt(y)
+			if !ok {
+				break
+			}
+
+			if tui.mode != ViewMode {
+				tui.SetMode(ViewMode)
+			}
+
+			tui.MoveCursorTo(line)
+
+			if action == tview.MouseLeftDoubleClick {
+				tui.ToggleMark()
+				tui.MoveCursorTo(line)
+			}
+		}
+
+		return nil, 0
+	}
+
+	if action != tview.MouseLeftClick && action != tview.MouseLeftDown {
+		return nil, 0
+	}
+
+	for idx, input := range tui.Inputs() {
+		if !input.tview.InRect(x, y) {
+			continue
+		}
+
+		// commands are typed into the first input; clicking it only moves the cursor
+		if tui.mode == CommandMode {
+			if idx == 0 {
+				return event, action
+			}
+			return nil, 0
+		}
+
+		if action == tview.MouseLeftDown {
+			tui.focusedField = idx
+
+			if tui.mode == EditMode {
+				tui.SetInputFocus()
+			} else {
+				tui.SetMode(EditMode)
+			}
+		}
+
+		// let the input move its cursor to where it was clicked
+		return event, action
+	}
+
+	return nil, 0
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// The screen-rows an output line takes, as drawn in the stdout viewer. Long lines take several rows when wrapped
+func (tui *TUI) LineHeight(idx int) int {
+	_, _, width, _ := tui.stdoutViewer.tview.GetInnerRect()
+
+	if !tui.stdoutViewer.wrap || width <= 0 {
+		return 1
+	}
+
+	gutter := 0
+	if tui.mode == ViewMode {
+		gutter = GUTTER_COLUMNS
+	}
+
+	if lineWidth := gutter + tview.TaggedStringWidth(tui.stdoutViewer.selection.lines.Styled(idx)); lineWidth > width {
+		return (lineWidth + width - 1) / width
+	}
+
+	return 1
+}
+
+// The first and last output lines on screen, and whether there's output to show them for
+func (tui *TUI) VisibleLines() (int, int, bool) {
+	selection := &tui.stdoutViewer.selection
+
+	if selection.Len() == 0 || tui.mode == HelpMode {
+		return 0, 0, false
+	}
+
+	_, _, _, height := tui.stdoutViewer.tview.GetInnerRect()
+
+	first := selection.top
+	last := first
+	rows := tui.LineHeight(first)
+
+	// a line that starts on screen is shown, even if it's cut off
+	for last+1 < selection.Len() && rows < height {
+		last++
+		rows += tui.LineHeight(last)
+	}
+
+	return first, last, true
+}
+
+// The furthest output can scroll; the last line, and any notice below it, at the bottom of the screen
+func (tui *TUI) LastTop() int {
+	selection := &tui.stdoutViewer.selection
+	_, _, _, height := tui.stdoutViewer.tview.GetInnerRect()
+
+	rows := 0
+	if selection.Notice() != "" {
+		rows = 1
+	}
+
+	top := selection.Len()
+	for top > 0 && rows+tui.LineHeight(top-1) <= height {
+		top--
+		rows += tui.LineHeight(top)
+	}
+
+	// the last line is taller than the screen
+	if top == selection.Len() && top > 0 {
+		top--
+	}
+
+	return top
+}
+
+// Scroll the least needed to show the whole cursor line, without scrolling past the end of the output
+func (tui *TUI) ScrollToCursor() {
+	selection := &tui.stdoutViewer.selection
+	_, _, _, height := tui.stdoutViewer.tview.GetInnerRect()
+
+	if selection.cursor < selection.top {
+		selection.top = selection.cursor
+	} else {
+		// the lowest first line that still shows the whole cursor line
+		top := selection.cursor
+		rows := tui.LineHeight(top)
+
+		// show the notice below the last line too
+		if top == selection.Len()-1 && selection.Notice() != "" {
+			rows++
+		}
+
+		for top > selection.top && rows+tui.LineHeight(top-1) <= height {
+			top--
+			rows += tui.LineHeight(top)
+		}
+
+		selection.top = top
+	}
+
+	if lastTop := tui.LastTop(); selection.top > lastTop {
+		selection.top = lastTop
+	}
+}
+
+// Move the cursor to a line, scrolling to keep it on screen
+func (tui *TUI) MoveCursorTo(line int) {
+	tui.MoveCursor(line - tui.stdoutViewer.selection.cursor)
+}
+
+// Scroll unwrapped output sideways by some columns
+func (tui *TUI) ScrollColumns(offset int) {
+	viewer := tui.stdoutViewer.tview
+
+	if tui.stdoutViewer.wrap {
+		return
+	}
+
+	row, col := viewer.GetScrollOffset()
+
+	if col += offset; col < 0 {
+		col = 0
+	}
+
+	viewer.ScrollTo(row, col)
+	tui.UpdateScrollPosition()
+}
+
+// Take the count typed before a navigation key, e.g 12 in 12g; one if no count was typed
+func (tui *TUI) TakeCount() (int, bool) {
+	count := tui.stdoutViewer.count
+	tui.stdoutViewer.count = 0
+
+	if count == 0 {
+		return 1, false
+	}
+
+	return count, true
+}
+
+// Build the count typed before a navigation key from a digit, vim-style. Returns whether the key was a digit
+func (tui *TUI) TakeDigit(event *tcell.EventKey) bool {
+	if event.Key() != tcell.KeyRune || event.Rune() < '0' || event.Rune() > '9' {
+		return false
+	}
+
+	digit := int(event.Rune() - '0')
+
+	// a leading zero isn't a count
+	if digit == 0 && tui.stdoutViewer.count == 0 {
+		return false
+	}
+
+	tui.stdoutViewer.count = tui.stdoutViewer.count*10 + digit
+	return true
+}
+
+// How many rows of output are on screen; the distance page-up and page-down move
+func (tui *TUI) PageHeight() int {
+	_, _, _, height := tui.stdoutViewer.tview.GetInnerRect()
+	return height
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// Keeps a preview command's standard-output, up to a limit of lines and bytes; output past
+// either limit is counted, but discarded, so huge outputs can't exhaust rl's memory
+type OutputStore struct {
+	lock      sync.Mutex
+	lines     []string       // each newline-terminated line kept, without its newline
+	partial   []byte         // a final line that hasn't been terminated yet
+	size      int            // the number of bytes kept
+	maxLines  int            // the most lines kept
+	maxBytes  int            // the most bytes kept
+	truncated bool           // was output discarded for exceeding a limit?
+	counter   CountingWriter // counts every line and byte written, including any discarded
+}
+
+func NewOutputStore(maxLines int, maxBytes int) *OutputStore {
+	return &OutputStore{maxLines: maxLines, maxBytes: maxBytes}
+}
+
+// Create a store for a preview command, with the limits from RL's configuration or the defaults for unset values
+func NewPreviewStore(cfg *ConfigOpts) *OutputStore {
+	maxLines := DEFAULT_MAX_OUTPUT_LINES
+	maxBytes := DEFAULT_MAX_OUTPUT_BYTES
+
+	if cfg.Config.MaxOutputLines > 0 {
+		maxLines = cfg.Config.MaxOutputLines
+	}
+
+	if cfg.Config.MaxOutputBytes > 0 {
+		maxBytes = cfg.Config.MaxOutputBytes
+	}
+
+	return NewOutputStore(maxLines, maxBytes)
+}
+
+// Keep output until a limit is reached. Writes always succeed, so the command isn't
+// sent SIGPIPE once output is being discarded
+func (store *OutputStore) Write(data []byte) (int, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	size, _ := store.counter.Write(data)
+
+	for len(data) > 0 && !store.truncated {
+		end := bytes.IndexByte(data, '\n')
+
+		if end < 0 {
+			if store.size+len(store.partial)+len(data) > store.maxBytes {
+				store.Truncate()
+				break
+			}
+
+			store.partial = append(store.partial, data...)
+			break
+		}
+
+		line := string(store.partial) + string(data[:end])
+
+		if len(store.lines) >= store.maxLines || store.size+len(line)+1 > store.maxBytes {
+			store.Truncate()
+			break
+		}
+
+		store.lines = append(store.lines, line)
+		store.size += len(line) + 1
+		store.partial = nil
+		data = data[end+1:]
+	}
+
+	return size, nil
+}
+
+// Stop keeping output; the partial line is dropped too, as it can't be completed
+func (store *OutputStore) Truncate() {
+	store.truncated = true
+	store.partial = nil
+}
+
+// How many lines are kept, including a final unterminated line, and how many of them are complete
+func (store *OutputStore) Len() (int, int) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if len(store.partial) > 0 {
+		return len(store.lines) + 1, len(store.lines)
+	}
+
+	return len(store.lines), len(store.lines)
+}
+
+// A line kept, as written; the final unterminated line follows the complete lines. Lines past
+// the last are empty, as the partial line is dropped if the output is truncated
+func (store *OutputStore) Line(idx int) string {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if idx < len(store.lines) {
+		return store.lines[idx]
+	}
+
+	if idx == len(store.lines) {
+		return string(store.partial)
+	}
+
+	return ""
+}
+
+// The output kept, as written
+func (store *OutputStore) Bytes() []byte {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	var buffer bytes.Buffer
+
+	if len(store.lines) > 0 {
+		buffer.WriteString(strings.Join(store.lines, "\n") + "\n")
+	}
+	buffer.Write(store.partial)
+
+	return buffer.Bytes()
+}
+
+// Was output discarded for exceeding a limit?
+func (store *OutputStore) Truncated() bool {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	return store.truncated
+}
+
+// How many lines and bytes the command wrote in all, including any discarded
+func (store *OutputStore) Written() (int, int) {
+	return store.counter.Lines(), store.counter.Bytes()
+}
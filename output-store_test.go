@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestOutputStore(t *testing.T) {
+	cases := []struct {
+		name      string
+		maxLines  int
+		maxBytes  int
+		writes    []string
+		lines     []string // the lines kept, including a final unterminated line
+		complete  int
+		truncated bool
+		written   int // the lines written, including any discarded
+	}{
+		{"under the limits", 10, 100, []string{"a\nb\n"}, []string{"a", "b"}, 2, false, 2},
+		{"lines split across writes", 10, 100, []string{"he", "llo\nwor", "ld\n"}, []string{"hello", "world"}, 2, false, 2},
+		{"a partial line", 10, 100, []string{"a\nb"}, []string{"a", "b"}, 1, false, 2},
+		{"the line cap", 2, 100, []string{"a\nb\nc\nd\n"}, []string{"a", "b"}, 2, true, 4},
+		{"the byte cap", 10, 5, []string{"ab\ncd\nef\n"}, []string{"ab"}, 1, true, 3},
+		{"a partial line past the byte cap", 10, 5, []string{"ab\n", "cdefgh"}, []string{"ab"}, 1, true, 2},
+		{"writes after truncating", 1, 100, []string{"a\nb\n", "c\n"}, []string{"a"}, 1, true, 3},
+	}
+
+	for _, testCase := range cases {
+		store := NewOutputStore(testCase.maxLines, testCase.maxBytes)
+
+		for _, data := range testCase.writes {
+			if size, err := store.Write([]byte(data)); err != nil || size != len(data) {
+				t.Errorf("%v: expected writes to succeed, got %v, %v", testCase.name, size, err)
+			}
+		}
+
+		total, complete := store.Len()
+		if total != len(testCase.lines) || complete != testCase.complete {
+			t.Errorf("%v: expected %v lines, %v complete; got %v, %v", testCase.name, len(testCase.lines), testCase.complete, total, complete)
+			continue
+		}
+
+		for idx, line := range testCase.lines {
+			if actual := store.Line(idx); actual != line {
+				t.Errorf("%v: expected line %v to be %q, got %q", testCase.name, idx, line, actual)
+			}
+		}
+
+		if store.Truncated() != testCase.truncated {
+			t.Errorf("%v: expected truncated to be %v", testCase.name, testCase.truncated)
+		}
+
+		if written, _ := store.Written(); written != testCase.written {
+			t.Errorf("%v: expected %v lines written, got %v", testCase.name, testCase.written, written)
+		}
+	}
+}
@@ -2,87 +2,321 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
-	"github.com/rivo/tview"
+	"github.com/creack/pty"
 )
 
 // Wait for started commands to complete.
-func AwaitCommand(cmd *exec.Cmd, stdoutBuffer *bytes.Buffer, tui *TUI) {
-	// wait performs cleanup tasks; without this a large number of threads pile-up in this process.
+func AwaitCommand(run *CommandRun, tui *TUI) {
+	// wait performs cleanup tasks; without this a large number of threads pile-up in this process,
+	// and stopped commands are left as zombies.
+
+	waitErr := run.cmd.Wait()
+	close(run.done)
+
+	// output read from a pseudo-terminal may still be being copied after the command exits. A background
+	// process can keep the terminal open for good, so stop copying its output after a moment
+	if run.copied != nil {
+		select {
+		case <-run.copied:
+		case <-time.After(PTY_DRAIN_MS * time.Millisecond):
+			run.terminal.Close()
+			<-run.copied
+		}
+	}
+
+	// a newer command replaced this one; its output and timings are no longer relevant, but
+	// redraw so output the newer command has written so far is shown
+	if run.output.Ignored() {
+		tui.Draw()
+		return
+	}
+
+	finished := time.Now()
+
+	// the output and viewers are only changed from the UI's goroutine, as key-handlers read them too
+	tui.app.tview.QueueUpdateDraw(func() {
+		// a newer command may have started while this update was queued
+		if run.output.Ignored() {
+			return
+		}
+
+		tui.SetStderr(run.stderr.store.Bytes())
 
-	cmd.Wait()
-	diff := time.Now().Sub(tui.state.commandStart)
+		if run.TimedOut() {
+			tui.UpdateTimedOut(run.timeout)
+		} else {
+			tui.UpdateRuntime(finished.Sub(tui.state.commandStart), CommandExitCode(waitErr))
+		}
 
-	tui.UpdateRuntime(diff)
-	tui.SetLineCount(stdoutBuffer)
-	tui.UpdateScrollPosition()
+		store := run.output.store
 
-	// TODO by default, scroll seems to lock to the bottom of the document. TODO may be annoying
-	// if you scrolled in view mode and tried to apply highlighting / line-number respecting filters.
-	tui.stdoutViewer.tview.ScrollToBeginning()
-	tui.Draw()
+		tui.SyncOutput(store)
+		tui.SetLineCount(tui.stdoutViewer.selection.Len())
+		tui.RenderOutput()
+		tui.UpdateScrollPosition()
+	})
+}
+
+// Show a running command's output and standard-error as they stream in. Only one update waits to
+// be shown at a time, so fast output doesn't flood the UI with redraws
+func (tui *TUI) QueueOutput(run *CommandRun) {
+	if !atomic.CompareAndSwapInt32(&run.output.queued, 0, 1) {
+		return
+	}
+
+	tui.app.tview.QueueUpdateDraw(func() {
+		atomic.StoreInt32(&run.output.queued, 0)
+
+		if run.output.Ignored() {
+			return
+		}
+
+		// once the command exits, AwaitCommand shows its output and final line-count
+		select {
+		case <-run.done:
+			return
+		default:
+		}
+
+		tui.SyncOutput(run.output.store)
+		tui.linePosition.lineCount = tui.stdoutViewer.selection.Len()
+		tui.RenderOutput()
+		tui.UpdateScrollPosition()
+		tui.SetStderr(run.stderr.store.Bytes())
+	})
 }
 
 type ClearWriter struct {
-	view    *tview.TextView
-	writer  io.Writer
-	cleared bool
+	store   *OutputStore // keeps the output, up to its limits
+	onWrite func()       // called after each write, e.g to show the new output
+	queued  int32        // is an update for the written output already waiting to be shown?
+	ignored bool         // discard output; the command is being stopped
+	lock    sync.Mutex   // guards ignored
 }
 
-// Defer to writer, but clear text-view on first write just-before
-// new output is written (minimising flickering in output)
+// Defer to the store; the viewer is cleared the first time the new output
+// is shown, just-before it's drawn (minimising flickering in output)
 func (tgt *ClearWriter) Write(data []byte) (n int, err error) {
-	if !tgt.cleared {
-		tgt.view.Lock()
-		tgt.view.Clear()
-		tgt.cleared = true
-		tgt.view.Unlock()
+	tgt.lock.Lock()
+
+	// pretend the write succeeded, so the command isn't sent SIGPIPE while it cleans up
+	if tgt.ignored {
+		tgt.lock.Unlock()
+		return len(data), nil
 	}
 
-	return tgt.writer.Write(data)
+	n, err = tgt.store.Write(data)
+	tgt.lock.Unlock()
+
+	if tgt.onWrite != nil {
+		tgt.onWrite()
+	}
+
+	return n, err
+}
+
+// Discard any further output
+func (tgt *ClearWriter) Ignore() {
+	tgt.lock.Lock()
+	defer tgt.lock.Unlock()
+
+	tgt.ignored = true
 }
 
-func NewClearWriter(view *tview.TextView) *ClearWriter {
+// Is further output being discarded?
+func (tgt *ClearWriter) Ignored() bool {
+	tgt.lock.Lock()
+	defer tgt.lock.Unlock()
+
+	return tgt.ignored
+}
+
+func NewClearWriter(store *OutputStore) *ClearWriter {
 	return &ClearWriter{
-		view,
-		tview.ANSIWriter(view),
-		false,
+		store: store,
+	}
+}
+
+// How rl stops a command it no longer needs. A signal is sent first, so the command can clean up;
+// if it's still running after a grace-period it is sent SIGKILL
+type StopPolicy struct {
+	signal syscall.Signal // the signal sent first
+	grace  time.Duration  // how long to wait for the command to exit before sending SIGKILL
+}
+
+// A preview command started by rl
+type CommandRun struct {
+	cmd      *exec.Cmd     // the running command
+	output   *ClearWriter  // where the command's output is kept, and shown from
+	stderr   *ClearWriter  // where the command's standard-error is kept, and shown from
+	done     chan struct{} // closed once the command has exited and been reaped
+	copied   chan struct{} // closed once output from a pseudo-terminal has been copied; nil when output is piped
+	terminal *os.File      // the pseudo-terminal output is copied from; nil when output is piped
+	policy   StopPolicy    // how to stop the command
+	timeout  time.Duration // how long the command may run for; zero is unlimited
+	expired  chan struct{} // closed if the command ran past its timeout
+}
+
+// Returned when the final run is terminated for running past final_timeout_ms
+var ErrCommandTimedOut = errors.New("command timed out")
+
+// Create a run for a command that's about to start
+func NewCommandRun(cmd *exec.Cmd, output *ClearWriter, stderr *ClearWriter, policy StopPolicy) *CommandRun {
+	return &CommandRun{
+		cmd:     cmd,
+		output:  output,
+		stderr:  stderr,
+		done:    make(chan struct{}),
+		policy:  policy,
+		expired: make(chan struct{}),
+	}
+}
+
+// Get the exit-code of a finished command from the error returned by waiting on it. Commands
+// killed by a signal, or that failed to start, have an exit-code of -1
+func CommandExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	if exitError, ok := err.(*exec.ExitError); ok {
+		return exitError.ExitCode()
+	}
+
+	return -1
+}
+
+// Parse a signal-name like SIGTERM, TERM, or sigint. Only signals that are sensible
+// for stopping a command are accepted
+func ParseSignal(name string) (syscall.Signal, error) {
+	normalised := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(name)), "SIG")
+
+	switch normalised {
+	case "TERM":
+		return syscall.SIGTERM, nil
+	case "INT":
+		return syscall.SIGINT, nil
+	case "HUP":
+		return syscall.SIGHUP, nil
+	case "KILL":
+		return syscall.SIGKILL, nil
+	default:
+		return 0, fmt.Errorf("unsupported signal '%v'; expected SIGTERM, SIGINT, SIGHUP, or SIGKILL", name)
+	}
+}
+
+// Read the stop-policy from RL's configuration, using defaults for unset values
+func NewStopPolicy(cfg *ConfigOpts) StopPolicy {
+	policy := StopPolicy{syscall.SIGTERM, DEFAULT_STOP_GRACE_MS * time.Millisecond}
+
+	if cfg.Config.StopSignal != "" {
+		// validated when RL starts
+		signal, _ := ParseSignal(cfg.Config.StopSignal)
+		policy.signal = signal
+	}
+
+	// zero is a valid grace-period; SIGKILL follows the signal straight away
+	if cfg.Config.StopGraceMs != nil {
+		policy.grace = time.Duration(*cfg.Config.StopGraceMs) * time.Millisecond
+	}
+
+	return policy
+}
+
+// Start a preview command attached to a pseudo-terminal sized to the output viewer, so tools that check
+// for a terminal stream their output line-by-line and colour it. Output is copied to the writer until the
+// terminal closes, then copied is closed
+func StartPtyCommand(cmd *exec.Cmd, tui *TUI, writer io.Writer) (*os.File, chan struct{}, error) {
+	size := &pty.Winsize{Rows: PTY_DEFAULT_ROWS, Cols: PTY_DEFAULT_COLUMNS}
+	_, _, width, height := tui.stdoutViewer.tview.GetInnerRect()
+
+	// the viewer has no size until it's first drawn; use a typical terminal's size until then
+	if width > 0 && height > 0 {
+		size = &pty.Winsize{Rows: uint16(height), Cols: uint16(width)}
+	}
+
+	// pty starts the command in a new session, which also makes it a process-group leader. Standard-input
+	// may not be the terminal, so take the controlling terminal from standard-output
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 1}
+
+	terminal, err := pty.StartWithAttrs(cmd, size, cmd.SysProcAttr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// pty leaves the terminal in blocking mode, where closing it doesn't stop a read in progress. Reopen it
+	// in non-blocking mode, so it can be closed while a background process keeps it open
+	if fd, err := syscall.Dup(int(terminal.Fd())); err == nil {
+		syscall.SetNonblock(fd, true)
+		terminal.Close()
+		terminal = os.NewFile(uintptr(fd), terminal.Name())
 	}
+
+	copied := make(chan struct{})
+
+	go func() {
+		// reading fails with EIO once every process using the terminal has exited
+		io.Copy(NewNewlineWriter(writer), terminal)
+		terminal.Close()
+		close(copied)
+	}()
+
+	return terminal, copied, nil
 }
 
 // Given the user-input, and contextual information, start a provided command in the user's shell
 // and point it at /dev/tty if in preview mode, or standard-output if the linebuffer is done. This command
 // will have access to an environmental variable containing the user's input
-func StartCommand(tui *TUI) (*exec.Cmd, error) {
-	// run the provided command in the user's shell. We don't know for certain -c is the correct
-	// flag, this wil vary between shells. but it works for zsh and bash.
+func StartCommand(tui *TUI) (*CommandRun, error) {
+	// run the provided command in the user's shell. How a shell takes an inline script varies, so this
+	// is looked up in SHELL_PROFILES; shells we don't know are assumed to take -c, like sh.
 
 	// only output the result of the last command-execution to standard-output; otherwise just show it on the tty
 	done := tui.GetDone()
 	ctx := tui.ctx
 	lineBuffer := tui.state.lineBuffer
 
-	cmd := exec.Command(ctx.shell, "-c", *ctx.execute)
+	var cmd *exec.Cmd
+
+	if ctx.argv != nil {
+		// run without a shell; the input can't be split or evaluated
+		args := SubstituteArgv(ctx.argv, lineBuffer, ctx.fields)
+		cmd = exec.Command(args[0], args[1:]...)
+	} else {
+		cmd = exec.Command(ctx.shell, ctx.profile.Args(*ctx.execute)...)
+	}
 
 	// is stdin present? If it is, StdinReader will have captured it.
 	piped, err := StdinPiped()
 	if err != nil {
-		return cmd, err
+		return nil, err
 	}
 
 	if piped {
 		// construct a new reader from stdin bytes
 		cmd.Stdin = bytes.NewReader(ctx.stdin.Bytes())
+	} else if !done && tui.cfg.Config.PreviewPty {
+		// don't let preview commands wait on input from the pseudo-terminal; nothing will type into it
+		cmd.Stdin = bytes.NewReader(nil)
 	}
 
 	varlist := []string{ENVAR_NAME_RL_INPUT + "=" + lineBuffer.content}
 
+	for idx, name := range ctx.fields {
+		varlist = append(varlist, name+"="+lineBuffer.fields[idx])
+	}
+
 	for _, pair := range ctx.envVars {
 		varlist = append(varlist, pair[0]+"="+pair[1])
 	}
@@ -90,18 +324,26 @@ func StartCommand(tui *TUI) (*exec.Cmd, error) {
 	// by default, go will use the current  process's environment. Merge RL_INPUT into that list and provide it to the command
 	cmd.Env = append(ctx.environment, varlist...)
 
-	var stdoutBuffer bytes.Buffer
+	var outputView *ClearWriter
+	var stderrView *ClearWriter
+
+	// the final run always writes to real pipes, so output redirected downstream behaves normally
+	usePty := !done && tui.cfg.Config.PreviewPty
 
 	if done {
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
+	} else if usePty {
+		// the pseudo-terminal's output is copied to outputview once the command starts
+		outputView = NewClearWriter(NewPreviewStore(tui.cfg))
+		stderrView = NewClearWriter(NewPreviewStore(tui.cfg))
 	} else {
-		outputView := NewClearWriter(tui.stdoutViewer.tview)
+		outputView = NewClearWriter(NewPreviewStore(tui.cfg))
+		stderrView = NewClearWriter(NewPreviewStore(tui.cfg))
 
-		// show intermixed, like a terminal would, by pipeing
-		// everthing to outputview
-		cmd.Stdout = io.MultiWriter(outputView, &stdoutBuffer)
-		cmd.Stderr = outputView
+		// standard-error is kept apart, so it can be shown in its own pane and isn't counted as output
+		cmd.Stdout = outputView
+		cmd.Stderr = stderrView
 	}
 	// set the pgid so we can terminate this child-process and its descendents with one signal later, if we need to
 	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
@@ -111,45 +353,134 @@ func StartCommand(tui *TUI) (*exec.Cmd, error) {
 		// I imagine I screwed up with os.Stdout handling here.
 		tui.Stop()
 
-		fmt.Fprintf(os.Stderr, SubstitueCommand(ctx.execute, &lineBuffer.content)+"\n")
-		finalErr := cmd.Run()
+		fmt.Fprintln(os.Stderr, ctx.CommandFor(lineBuffer))
+
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+
+		run := NewCommandRun(cmd, nil, nil, NewStopPolicy(tui.cfg))
+		run.StartTimeout(time.Duration(tui.cfg.Config.FinalTimeoutMs) * time.Millisecond)
+
+		finalErr := cmd.Wait()
+		close(run.done)
+
+		if run.TimedOut() {
+			fmt.Fprintf(os.Stderr, "RL: command timed out after %vms\n", run.timeout.Milliseconds())
+			return nil, ErrCommandTimedOut
+		}
 
 		return nil, finalErr
 	} else {
 		// start the command, but don't wait for the command to complete or error-check that it started
 
+		run := NewCommandRun(cmd, outputView, stderrView, NewStopPolicy(tui.cfg))
+		outputView.onWrite = func() {
+			tui.QueueOutput(run)
+		}
+		stderrView.onWrite = outputView.onWrite
+
 		tui.state.commandStart = time.Now()
-		cmd.Start()
-		go AwaitCommand(cmd, &stdoutBuffer, tui)
-	}
 
-	if err != nil {
-		return nil, err
-	} else {
-		return cmd, nil
+		if usePty {
+			// a terminal has one output stream, so standard-error is shown and counted with standard-output
+			terminal, copied, err := StartPtyCommand(cmd, tui, outputView)
+			if err != nil {
+				return nil, err
+			}
+			run.terminal = terminal
+			run.copied = copied
+		} else if err := cmd.Start(); err != nil {
+			// most likely the program doesn't exist; show why in the standard-error pane. This is written
+			// to the store directly, as queueing an update from the UI's goroutine would wait on itself
+			fmt.Fprintf(stderrView.store, "rl: %v\n", err)
+		}
+
+		run.StartTimeout(time.Duration(tui.cfg.Config.TimeoutMs) * time.Millisecond)
+		go AwaitCommand(run, tui)
+
+		return run, nil
 	}
 }
 
-// Stop a running execute process by looking up the state's cmd variable,
-// and if it's present send a SIGKILL signal to the child-process (the user's spawned shell) and
-// the processes started by it. This is important to stop slow-running commands from making this tool
-// feel laggy; we're running a process for the new user-input as fast as possible
+// Stop a running execute process by looking up the state's run variable, and if it's present
+// signal the child-process (the user's spawned shell) and the processes started by it. This is
+// important to stop slow-running commands from making this tool feel laggy; we're running a
+// process for the new user-input as fast as possible.
+//
+// Output from the stopped process is ignored straight away, but the process is given a grace-period to
+// exit and clean up (remove lock-files, temporary files) before it's sent SIGKILL. fzf sends SIGKILL
+// immediately, which can be configured with stop_signal.
 func (state *LineChangeState) StopProcess() error {
-	cmd := state.cmd
+	run := state.run
 
-	if cmd == nil {
+	if run == nil || run.cmd.Process == nil {
 		return nil
 	}
 
-	pgid, err := syscall.Getpgid(cmd.Process.Pid)
+	state.run = nil
+	run.output.Ignore()
+	run.stderr.Ignore()
 
-	if err != nil {
-		return err
+	return run.Terminate()
+}
+
+// Signal the command's process-group with the stop-policy's signal, and send SIGKILL if it's
+// still running once the grace-period is over
+func (run *CommandRun) Terminate() error {
+	// we set the pgid to the child's pid when starting it; this still reaches the process-group
+	// after the child itself has exited
+	pgid := run.cmd.Process.Pid
+
+	select {
+	case <-run.done:
+		// the command already finished
+		return nil
+	default:
 	}
 
-	// this seems like overkill (hah) but fzf sends this signal rather than the SIGTERM I initially went with,
-	// and I trust their decision
-	return syscall.Kill(-pgid, syscall.SIGKILL)
+	err := syscall.Kill(-pgid, run.policy.signal)
+
+	if run.policy.signal != syscall.SIGKILL {
+		go func() {
+			select {
+			case <-run.done:
+			case <-time.After(run.policy.grace):
+				syscall.Kill(-pgid, syscall.SIGKILL)
+			}
+		}()
+	}
+
+	return err
+}
+
+// Terminate the command if it's still running once the timeout has passed. A timeout of
+// zero means the command can run for as long as it likes
+func (run *CommandRun) StartTimeout(timeout time.Duration) {
+	run.timeout = timeout
+
+	if timeout <= 0 {
+		return
+	}
+
+	go func() {
+		select {
+		case <-run.done:
+		case <-time.After(timeout):
+			close(run.expired)
+			run.Terminate()
+		}
+	}()
+}
+
+// Was the command terminated because it ran past its timeout?
+func (run *CommandRun) TimedOut() bool {
+	select {
+	case <-run.expired:
+		return true
+	default:
+		return false
+	}
 }
 
 // Takes the current application state, and some context variables, and run a few steps:
@@ -166,7 +497,15 @@ func (state LineChangeState) HandleUserUpdate(tui *TUI) (LineChangeState, error)
 		// we don't case about final command execution; just print what
 		// the user inputted and exit.
 		tui.Stop()
-		fmt.Println(tui.state.lineBuffer.content)
+
+		if tui.ctx.fields != nil {
+			// print each field on its own line, in the order they were declared
+			for _, field := range tui.state.lineBuffer.fields {
+				fmt.Println(field)
+			}
+		} else {
+			fmt.Println(tui.state.lineBuffer.content)
+		}
 
 		go func(exitChan chan int) {
 			exitChan <- 0
@@ -176,12 +515,14 @@ func (state LineChangeState) HandleUserUpdate(tui *TUI) (LineChangeState, error)
 	}
 
 	// call the command
-	cmd, cmdErr := StartCommand(tui)
+	run, cmdErr := StartCommand(tui)
 
 	// if done, handle exit codes
 	if done {
 		go func(exitChan chan int) {
-			if exitError, ok := cmdErr.(*exec.ExitError); ok {
+			if errors.Is(cmdErr, ErrCommandTimedOut) {
+				exitChan <- TIMEOUT_EXIT_CODE
+			} else if exitError, ok := cmdErr.(*exec.ExitError); ok {
 				exitChan <- exitError.ExitCode()
 			} else if cmdErr != nil {
 				// it faied, we don't know why
@@ -197,7 +538,7 @@ func (state LineChangeState) HandleUserUpdate(tui *TUI) (LineChangeState, error)
 	if cmdErr != nil {
 		return state, cmdErr
 	} else {
-		state.cmd = cmd
+		state.run = run
 	}
 
 	return state, nil
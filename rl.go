@@ -10,7 +10,7 @@ func RL(opts docopt.Opts) int {
 		return code
 	}
 
-	state, ctx, code := RLState(&opts)
+	state, ctx, code := RLState(&opts, cfg)
 	if code != 0 {
 		return code
 	}
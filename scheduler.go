@@ -0,0 +1,128 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Decides when commands run, so that typing quickly doesn't start a process per key-stroke.
+// Runs are debounced until typing pauses, and limited to a maximum number of starts per second.
+// The scheduler is used from tview's event-loop; only the pending timer is read elsewhere
+type CommandScheduler struct {
+	lock         sync.Mutex      // guards timer
+	debounce     time.Duration   // how long typing must pause before a command runs
+	maxPerSecond int             // the most commands started in any one second. Zero is unlimited
+	starts       []time.Time     // when recent commands started, for rate-limiting
+	timer        *time.Timer     // a pending run, if any
+	generation   int             // incremented on each reschedule, so stale timers do nothing
+	queue        func(fn func()) // run a function on the event-loop
+}
+
+// Create a scheduler from RL's configuration
+func NewCommandScheduler(cfg *ConfigOpts, queue func(fn func())) *CommandScheduler {
+	return &CommandScheduler{
+		debounce:     time.Duration(cfg.Config.DebounceMs) * time.Millisecond,
+		maxPerSecond: cfg.Config.MaxSpawnsPerSecond,
+		queue:        queue,
+	}
+}
+
+// Get how long the rate-limit requires us to wait before starting another command
+func (sched *CommandScheduler) RateDelay(now time.Time) time.Duration {
+	if sched.maxPerSecond <= 0 {
+		return 0
+	}
+
+	// forget commands started more than a second ago
+	recent := []time.Time{}
+	for _, start := range sched.starts {
+		if now.Sub(start) < time.Second {
+			recent = append(recent, start)
+		}
+	}
+	sched.starts = recent
+
+	if len(recent) < sched.maxPerSecond {
+		return 0
+	}
+
+	return time.Second - now.Sub(recent[0])
+}
+
+// Record that a command started, for rate-limiting
+func (sched *CommandScheduler) RecordStart(now time.Time) {
+	sched.starts = append(sched.starts, now)
+}
+
+// Is a run waiting to start?
+func (sched *CommandScheduler) Pending() bool {
+	sched.lock.Lock()
+	defer sched.lock.Unlock()
+
+	return sched.timer != nil
+}
+
+// Cancel any pending run
+func (sched *CommandScheduler) Cancel() {
+	sched.generation++
+
+	sched.lock.Lock()
+	defer sched.lock.Unlock()
+
+	if sched.timer != nil {
+		sched.timer.Stop()
+		sched.timer = nil
+	}
+}
+
+// Run a function on the event-loop after a delay, replacing any pending run
+func (sched *CommandScheduler) After(delay time.Duration, fn func()) {
+	sched.Cancel()
+	generation := sched.generation
+
+	sched.lock.Lock()
+	defer sched.lock.Unlock()
+
+	sched.timer = time.AfterFunc(delay, func() {
+		sched.queue(func() {
+			// a newer run was scheduled after this timer fired
+			if generation != sched.generation {
+				return
+			}
+
+			sched.lock.Lock()
+			sched.timer = nil
+			sched.lock.Unlock()
+
+			fn()
+		})
+	})
+}
+
+// Run the command once typing pauses and the rate-limit allows it
+func (tui *TUI) ScheduleCommand() {
+	tui.UpdatePreview()
+
+	if tui.scheduler.debounce > 0 {
+		tui.UpdateDeferred(tui.scheduler.debounce)
+		tui.scheduler.After(tui.scheduler.debounce, tui.RunScheduledCommand)
+		return
+	}
+
+	tui.scheduler.Cancel()
+	tui.RunScheduledCommand()
+}
+
+// Run the command now, unless too many commands started recently; then try again later
+func (tui *TUI) RunScheduledCommand() {
+	now := time.Now()
+
+	if wait := tui.scheduler.RateDelay(now); wait > 0 {
+		tui.UpdateDeferred(wait)
+		tui.scheduler.After(wait, tui.RunScheduledCommand)
+		return
+	}
+
+	tui.scheduler.RecordStart(now)
+	tui.RunCommand()
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/rivo/tview"
+)
+
+// Matches the colour-tags tview draws as styles, and the escaped brackets it draws as text.
+// These mirror the patterns tview uses itself
+var STYLE_TAG_PATTERN = regexp.MustCompile(`\[([a-zA-Z]+|#[0-9a-zA-Z]{6}|\-)?(:([a-zA-Z]+|#[0-9a-zA-Z]{6}|\-)?(:([lbdru]+|\-)?)?)?\]`)
+var ESCAPED_TAG_PATTERN = regexp.MustCompile(`\[([a-zA-Z0-9_,;: \-\."#]+)\[(\[*)\]`)
+var STYLED_TOKEN_PATTERN = regexp.MustCompile(ESCAPED_TAG_PATTERN.String() + "|" + STYLE_TAG_PATTERN.String())
+
+// A piece of a line formatted for tview; either a tag that changes the style, or text that's drawn
+type styledSegment struct {
+	text    string // the segment, as written in the line
+	visible []rune // the characters drawn for the segment
+	tag     bool   // does the segment change the style, rather than draw anything?
+}
+
+// An occurrence of the search text in the output
+type SearchMatch struct {
+	line int // the output line containing the match
+	nth  int // which match this is, within the line
+}
+
+// The text searched for in view mode, and where it was found
+type OutputSearch struct {
+	query   string        // the text searched for; empty when not searching
+	matches []SearchMatch // each occurrence of the query, in output order
+	current int           // the match the cursor was last moved to
+}
+
+// Split a line formatted for tview into style-tags, and the text drawn between them
+func splitStyled(line string) []styledSegment {
+	segments := []styledSegment{}
+	start := 0
+
+	for _, loc := range STYLED_TOKEN_PATTERN.FindAllStringIndex(line, -1) {
+		if loc[0] > start {
+			segments = append(segments, styledSegment{line[start:loc[0]], []rune(line[start:loc[0]]), false})
+		}
+
+		token := line[loc[0]:loc[1]]
+
+		if escaped := ESCAPED_TAG_PATTERN.FindStringSubmatch(token); escaped != nil && escaped[0] == token {
+			// escaped brackets are drawn without the inner bracket
+			segments = append(segments, styledSegment{token, []rune("[" + escaped[1] + escaped[2] + "]"), false})
+		} else {
+			segments = append(segments, styledSegment{token, nil, true})
+		}
+
+		start = loc[1]
+	}
+
+	if start < len(line) {
+		segments = append(segments, styledSegment{line[start:], []rune(line[start:]), false})
+	}
+
+	return segments
+}
+
+// The text tview draws for a formatted line
+func visibleText(segments []styledSegment) []rune {
+	visible := []rune{}
+
+	for _, segment := range segments {
+		visible = append(visible, segment.visible...)
+	}
+
+	return visible
+}
+
+// Search is case-insensitive unless the query contains an upper-case character
+func foldCase(text []rune, query string) []rune {
+	if strings.IndexFunc(query, unicode.IsUpper) != -1 {
+		return text
+	}
+
+	folded := make([]rune, len(text))
+	for idx, char := range text {
+		folded[idx] = unicode.ToLower(char)
+	}
+
+	return folded
+}
+
+// Find where each non-overlapping occurrence of the query starts in some text
+func FindOccurrences(text []rune, query string) []int {
+	needle := foldCase([]rune(query), query)
+	haystack := foldCase(text, query)
+	starts := []int{}
+
+	if len(needle) == 0 {
+		return starts
+	}
+
+	for idx := 0; idx+len(needle) <= len(haystack); idx++ {
+		if string(haystack[idx:idx+len(needle)]) == string(needle) {
+			starts = append(starts, idx)
+			idx += len(needle) - 1
+		}
+	}
+
+	return starts
+}
+
+// The tag a search match is drawn with; the match the cursor moved to stands out
+func matchTag(current bool) string {
+	if current {
+		return "[black:orange]"
+	}
+
+	return "[black:yellow]"
+}
+
+// A run of drawn characters in a line, from start up to end
+type TextRange struct {
+	start int
+	end   int
+}
+
+// Highlight each occurrence of the query in a line formatted for tview. current is the
+// match to draw as the current match, or -1
+func HighlightMatches(line string, query string, current int) string {
+	segments := splitStyled(line)
+	width := len([]rune(query))
+	ranges := []TextRange{}
+
+	for _, start := range FindOccurrences(visibleText(segments), query) {
+		ranges = append(ranges, TextRange{start, start + width})
+	}
+
+	return HighlightRanges(segments, ranges, func(nth int) string {
+		return matchTag(nth == current)
+	})
+}
+
+// Draw runs of characters in a line with the tag for each run. The line's own styles are
+// restored after each run, so colours from ANSI output are kept
+func HighlightRanges(segments []styledSegment, ranges []TextRange, tagFor func(nth int) string) string {
+	var out strings.Builder
+
+	if len(ranges) == 0 {
+		for _, segment := range segments {
+			out.WriteString(segment.text)
+		}
+		return out.String()
+	}
+
+	// which run, if any, each drawn character belongs to
+	inMatch := make([]int, len(visibleText(segments)))
+	for idx := range inMatch {
+		inMatch[idx] = -1
+	}
+	for nth, run := range ranges {
+		for pos := run.start; pos < run.end && pos < len(inMatch); pos++ {
+			inMatch[pos] = nth
+		}
+	}
+
+	tags := []string{}
+	active := -1
+	pos := 0
+
+	// switch between drawing a match and the line's own style
+	moveTo := func(nth int) {
+		if nth == active {
+			return
+		}
+		if active >= 0 {
+			out.WriteString("[-:-:-]" + strings.Join(tags, ""))
+		}
+		if nth >= 0 {
+			out.WriteString(tagFor(nth))
+		}
+		active = nth
+	}
+
+	for _, segment := range segments {
+		if segment.tag {
+			out.WriteString(segment.text)
+			tags = append(tags, segment.text)
+
+			// the line changed style mid-match; keep drawing the match
+			if active >= 0 {
+				out.WriteString(tagFor(active))
+			}
+			continue
+		}
+
+		if string(segment.visible) != segment.text {
+			// escaped brackets are highlighted whole, so they aren't split apart
+			moveTo(inMatch[pos])
+			out.WriteString(segment.text)
+			pos += len(segment.visible)
+			continue
+		}
+
+		for _, char := range segment.visible {
+			moveTo(inMatch[pos])
+			out.WriteRune(char)
+			pos++
+		}
+	}
+
+	moveTo(-1)
+
+	return out.String()
+}
+
+// Search the output for some text, and move the cursor to the first match at or after it.
+// Searching for nothing stops searching
+func (tui *TUI) Find(query string) error {
+	selection := &tui.stdoutViewer.selection
+	search := &tui.stdoutViewer.search
+
+	if query == "" {
+		*search = OutputSearch{}
+		tui.RenderOutput()
+		tui.UpdateScrollPosition()
+		return nil
+	}
+
+	matches := []SearchMatch{}
+	for idx := 0; idx < selection.Len(); idx++ {
+		for nth := range FindOccurrences(visibleText(splitStyled(selection.lines.Styled(idx))), query) {
+			matches = append(matches, SearchMatch{idx, nth})
+		}
+	}
+
+	if len(matches) == 0 {
+		return fmt.Errorf("no matches for '%v'", query)
+	}
+
+	*search = OutputSearch{query, matches, 0}
+	for idx, match := range matches {
+		if match.line >= selection.cursor {
+			search.current = idx
+			break
+		}
+	}
+
+	tui.JumpToMatch()
+	return nil
+}
+
+// Move to the next or previous match, wrapping around the output
+func (tui *TUI) CycleMatch(offset int) {
+	search := &tui.stdoutViewer.search
+
+	if len(search.matches) == 0 {
+		return
+	}
+
+	count := len(search.matches)
+	search.current = ((search.current+offset)%count + count) % count
+
+	tui.JumpToMatch()
+}
+
+// Move the cursor to the current match
+func (tui *TUI) JumpToMatch() {
+	search := &tui.stdoutViewer.search
+	tui.stdoutViewer.selection.cursor = search.matches[search.current].line
+	tui.MoveCursor(0)
+}
+
+// The current match within a line, or -1 if the current match is elsewhere
+func (search *OutputSearch) CurrentIn(line int) int {
+	if len(search.matches) == 0 {
+		return -1
+	}
+
+	if match := search.matches[search.current]; match.line == line {
+		return match.nth
+	}
+
+	return -1
+}
+
+// The search position shown in the header, e.g "match 3/41"
+func (search *OutputSearch) Position() string {
+	return fmt.Sprintf("match %v/%v", search.current+1, len(search.matches))
+}
+
+// :find TEXT searches the output, highlighting each match; n and N move between them
+func RunFindCommand(tui *TUI, args string) error {
+	if tui.stdoutViewer.selection.Len() == 0 {
+		return errors.New("there's no output to search")
+	}
+
+	if err := tui.Find(args); err != nil {
+		return err
+	}
+
+	if args == "" {
+		tui.FinishInternalCommand("search cleared")
+	} else {
+		tui.FinishInternalCommand(tui.stdoutViewer.search.Position() + " for '" + tview.Escape(args) + "'; press [green]n[-:-:-] or [green]N[-:-:-] for the next, previous match")
+	}
+
+	return nil
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// The lines shown in the stdout viewer. They're read as they're drawn, so output is only kept once
+type OutputLines interface {
+	Len() int              // the number of lines
+	Plain(idx int) string  // a line without colours, as it's printed, copied, or written to a file
+	Styled(idx int) string // a line with tview's colour-tags, as it's drawn
+}
+
+// A command's output, read from its store. Only the colours each line starts with are kept
+// alongside the store, so lines can be translated one at a time as they're drawn
+type StoreLines struct {
+	store  *OutputStore
+	starts []string    // the tag restoring the colours each complete line starts with
+	styles *ANSIStyles // follows the colours set by the complete lines read so far
+	count  int         // the number of lines read from the store, including a final unterminated line
+}
+
+func NewStoreLines(store *OutputStore) *StoreLines {
+	return &StoreLines{store: store, styles: NewANSIStyles()}
+}
+
+// Read the lines written to the store since it was last read
+func (lines *StoreLines) Sync() {
+	total, complete := lines.store.Len()
+
+	for idx := len(lines.starts); idx < complete; idx++ {
+		tag := lines.styles.Tag()
+
+		// most lines start with the same colours as the last, so share its tag
+		if idx > 0 && lines.starts[idx-1] == tag {
+			tag = lines.starts[idx-1]
+		}
+
+		lines.starts = append(lines.starts, tag)
+		lines.styles.Advance(lines.store.Line(idx))
+	}
+
+	lines.count = total
+}
+
+func (lines *StoreLines) Len() int {
+	return lines.count
+}
+
+func (lines *StoreLines) Plain(idx int) string {
+	return StripANSI(lines.store.Line(idx))
+}
+
+// A line that's still being written starts with the colours the complete lines left set
+func (lines *StoreLines) Styled(idx int) string {
+	tag := lines.styles.Tag()
+	if idx < len(lines.starts) {
+		tag = lines.starts[idx]
+	}
+
+	return TranslateANSIFrom(tag, lines.store.Line(idx))
+}
+
+// The lines of the last output, the line under the cursor, and the lines marked in view mode
+type OutputSelection struct {
+	lines  OutputLines  // the lines of output; nil before any are shown
+	cursor int          // the line under the cursor
+	marked map[int]bool // the lines marked with Space or Tab
+	top    int          // the first line drawn in the stdout viewer
+	source *StoreLines  // the command output the lines are read from; nil for lines rl made itself, like filter matches
+}
+
+// The number of output lines
+func (selection *OutputSelection) Len() int {
+	if selection.lines == nil {
+		return 0
+	}
+
+	return selection.lines.Len()
+}
+
+// Every output line without colours; read when it's needed, as it copies the output
+func (selection *OutputSelection) PlainLines() []string {
+	lines := make([]string, selection.Len())
+	for idx := range lines {
+		lines[idx] = selection.lines.Plain(idx)
+	}
+
+	return lines
+}
+
+// Replace the output lines; the cursor returns to the first line, and marks and searches are cleared
+func (tui *TUI) SetOutputLines(lines OutputLines) {
+	tui.stdoutViewer.selection = OutputSelection{lines: lines, marked: map[int]bool{}}
+	tui.stdoutViewer.search = OutputSearch{}
+	tui.stdoutViewer.tview.ScrollToBeginning()
+}
+
+// Read the lines written to a command's output store since it was last read. Output from a
+// different store replaces the current lines, and clears the viewer
+func (tui *TUI) SyncOutput(store *OutputStore) {
+	selection := &tui.stdoutViewer.selection
+
+	if selection.source == nil || selection.source.store != store {
+		source := NewStoreLines(store)
+		tui.SetOutputLines(source)
+		selection.source = source
+		tui.stdoutViewer.tview.SetText("")
+	}
+
+	selection.source.Sync()
+
+	// a partial line is dropped if the output is truncated before it's finished
+	if selection.cursor >= selection.Len() {
+		selection.cursor = 0
+		selection.top = 0
+		selection.marked = map[int]bool{}
+	}
+}
+
+// Shown below the last line when output was discarded for exceeding the store's limits
+func (selection *OutputSelection) Notice() string {
+	if selection.source == nil {
+		return ""
+	}
+
+	if !selection.source.store.Truncated() {
+		return ""
+	}
+
+	lines, size := selection.source.store.Written()
+	return fmt.Sprintf("[yellow]-- output truncated at %v lines; the command wrote %v lines, %v bytes --[-:-:-]", selection.Len(), lines, size)
+}
+
+// The number of lines the command wrote, if more were written than kept; zero otherwise
+func (selection *OutputSelection) Written() int {
+	if selection.source == nil || !selection.source.store.Truncated() {
+		return 0
+	}
+
+	lines, _ := selection.source.store.Written()
+	return lines
+}
+
+// Show the output lines on screen in the stdout viewer; only those are rendered, so huge outputs
+// draw quickly. In view mode, marked lines are shown in the gutter and the line under the cursor is reversed
+func (tui *TUI) RenderOutput() {
+	selection := &tui.stdoutViewer.selection
+	viewer := tui.stdoutViewer.tview
+
+	first, last, ok := tui.VisibleLines()
+	if !ok {
+		return
+	}
+
+	choosing := tui.mode == ViewMode
+	search := &tui.stdoutViewer.search
+	highlighter := tui.NewInputHighlighter()
+	var text strings.Builder
+
+	for idx := first; idx <= last; idx++ {
+		line := selection.lines.Styled(idx)
+
+		if choosing {
+			if selection.marked[idx] {
+				text.WriteString("[yellow::b]*[-:-:-] ")
+			} else {
+				text.WriteString("  ")
+			}
+
+			// the cursor line is shown reversed. Its own colours are dropped, as they'd reset the reversal
+			if idx == selection.cursor {
+				line = "[::r]" + tview.Escape(selection.lines.Plain(idx))
+			}
+		}
+
+		if highlighter != nil {
+			line = highlighter.Highlight(line)
+		}
+
+		if choosing && search.query != "" {
+			line = HighlightMatches(line, search.query, search.CurrentIn(idx))
+		}
+
+		text.WriteString(line + "[-:-:-]\n")
+	}
+
+	if last == selection.Len()-1 {
+		if notice := selection.Notice(); notice != "" {
+			text.WriteString(notice + "\n")
+		}
+	}
+
+	_, col := viewer.GetScrollOffset()
+
+	viewer.SetTextAlign(tview.AlignLeft)
+	viewer.SetText(text.String())
+	viewer.ScrollTo(0, col)
+}
+
+// Move the cursor by some lines, scrolling to keep it on screen
+func (tui *TUI) MoveCursor(offset int) {
+	selection := &tui.stdoutViewer.selection
+
+	if selection.Len() == 0 {
+		return
+	}
+
+	selection.cursor += offset
+
+	if selection.cursor < 0 {
+		selection.cursor = 0
+	} else if selection.cursor >= selection.Len() {
+		selection.cursor = selection.Len() - 1
+	}
+
+	tui.ScrollToCursor()
+	tui.RenderOutput()
+	tui.UpdateScrollPosition()
+}
+
+// Mark or unmark the line under the cursor, then move to the next line
+func (tui *TUI) ToggleMark() {
+	selection := &tui.stdoutViewer.selection
+
+	if selection.Len() == 0 {
+		return
+	}
+
+	if selection.marked[selection.cursor] {
+		delete(selection.marked, selection.cursor)
+	} else {
+		selection.marked[selection.cursor] = true
+	}
+
+	tui.MoveCursor(1)
+}
+
+// The marked lines in output order, or the line under the cursor if none are marked
+func (selection *OutputSelection) Chosen() []string {
+	if selection.Len() == 0 {
+		return []string{}
+	}
+
+	if len(selection.marked) == 0 {
+		return []string{selection.lines.Plain(selection.cursor)}
+	}
+
+	indices := []int{}
+	for idx := range selection.marked {
+		indices = append(indices, idx)
+	}
+	sort.Ints(indices)
+
+	chosen := []string{}
+	for _, idx := range indices {
+		chosen = append(chosen, selection.lines.Plain(idx))
+	}
+
+	return chosen
+}
+
+// Should Enter print the chosen lines in view mode, rather than run the command a final time?
+// Marked lines are always printed; the line under the cursor only when configured, or when filtering
+func (tui *TUI) ChoosesOnEnter() bool {
+	selection := &tui.stdoutViewer.selection
+
+	if len(selection.marked) > 0 {
+		return true
+	}
+
+	return selection.Len() > 0 && (tui.ctx.filter != "" || tui.cfg.Config.SelectOnEnter)
+}
+
+// Print the chosen lines, and exit
+func (tui *TUI) PrintChosen() {
+	chosen := tui.stdoutViewer.selection.Chosen()
+	tui.Stop()
+
+	for _, line := range chosen {
+		fmt.Println(line)
+	}
+
+	go func(exitChan chan int) {
+		exitChan <- 0
+	}(tui.chans.exitCode)
+}
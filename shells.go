@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// How a shell takes an inline script, and how scripts refer to rl's input
+type ShellProfile struct {
+	name       string     // the shell's executable name, e.g fish
+	scriptArgs []string   // the arguments preceding an inline script, e.g -c
+	varPrefix  string     // the prefix used to read an environment-variable in this shell's syntax, e.g $
+	audit      ShellAudit // how templates for this shell are checked for dangerous uses of user-input
+}
+
+// Shells rl knows how to invoke. Other shells are assumed to take -c like sh, but their templates can't be checked
+var SHELL_PROFILES = []ShellProfile{
+	{"sh", []string{"-c"}, "$", PosixAudit},
+	{"bash", []string{"-c"}, "$", PosixAudit},
+	{"zsh", []string{"-c"}, "$", PosixAudit},
+	{"ksh", []string{"-c"}, "$", PosixAudit},
+	{"dash", []string{"-c"}, "$", PosixAudit},
+	{"fish", []string{"-c"}, "$", WordAudit},
+	{"nu", []string{"-c"}, "$env.", WordAudit},
+	{"pwsh", []string{"-NoProfile", "-NonInteractive", "-Command"}, "$env:", WordAudit},
+	{"powershell", []string{"-NoProfile", "-NonInteractive", "-Command"}, "$env:", WordAudit},
+}
+
+// Find the profile for a shell from its path or name, e.g /usr/bin/fish
+func FindShellProfile(shell string) ShellProfile {
+	name := strings.TrimSuffix(filepath.Base(shell), ".exe")
+
+	for _, profile := range SHELL_PROFILES {
+		if profile.name == name {
+			return profile
+		}
+	}
+
+	return ShellProfile{name, []string{"-c"}, "$", NoAudit}
+}
+
+// The arguments that run a script in this shell
+func (profile ShellProfile) Args(script string) []string {
+	return append(append([]string{}, profile.scriptArgs...), script)
+}
+
+// How a script refers to an environment-variable in this shell, e.g $RL_INPUT, or $env.RL_INPUT in nushell
+func (profile ShellProfile) Var(name string) string {
+	return profile.varPrefix + name
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"math"
+	"sort"
 	"strings"
 	"time"
 
@@ -21,8 +22,14 @@ type TUI struct {
 	latency        *TUILatencyViewer
 	linePosition   *TUILinePosition
 	stdoutViewer   *TUITextViewer
+	stderrViewer   *TUIStderrViewer
 	commandInput   *TUICommandInput
+	fieldInputs    []*TUICommandInput // inputs for the second and later fields given with -f
+	focusedField   int                // the input field being edited; 0 is commandInput
 	helpBar        *TUIHelpBar
+	historySearch  *TUIHistorySearch
+	pages          *tview.Pages
+	outputPanes    *tview.Flex // standard-output, with the standard-error pane below it
 	chans          struct {
 		history  chan *History
 		exitCode chan int
@@ -30,10 +37,29 @@ type TUI struct {
 	mode      PromptMode
 	textAlign int
 	history   HistoryCursor
+	scheduler *CommandScheduler
+	keys      KeyBindings  // the keys bound to each action, by mode
+	filter    *StdinFilter // standard-input split into lines, in filter mode
 }
 
-// provide some display of how long slow commands ran for
-func (tui *TUI) UpdateRuntime(diff time.Duration) {
+// Show an exit-code; green for success, red for failure
+func FormatExitCode(code int) string {
+	if code == 0 {
+		return "[green]exit 0[-:-:-]"
+	} else if code < 0 {
+		return "[red]killed[-:-:-]"
+	}
+
+	return "[red]exit " + fmt.Sprint(code) + "[-:-:-]"
+}
+
+// provide some display of how long slow commands ran for, and how they exited
+func (tui *TUI) UpdateRuntime(diff time.Duration, exitCode int) {
+	// keep showing that the next run is deferred, rather than how long the last one took
+	if tui.scheduler.Pending() {
+		return
+	}
+
 	ms := diff.Milliseconds()
 	msg := fmt.Sprint(ms) + "ms"
 
@@ -45,8 +71,21 @@ func (tui *TUI) UpdateRuntime(diff time.Duration) {
 		msg = "[red]" + msg + "[-:-:-]"
 	}
 
-	tui.latency.tview.SetText(msg)
-	tui.Draw()
+	tui.latency.tview.SetText(FormatExitCode(exitCode) + " " + msg)
+}
+
+// show that a command is waiting to run, rather than running
+func (tui *TUI) UpdateDeferred(wait time.Duration) {
+	tui.latency.tview.SetText("[gray]deferred " + fmt.Sprint(wait.Milliseconds()) + "ms[-:-:-]")
+}
+
+// show that the last command was stopped for running too long, rather than how long it took
+func (tui *TUI) UpdateTimedOut(timeout time.Duration) {
+	if tui.scheduler.Pending() {
+		return
+	}
+
+	tui.latency.tview.SetText("[red]timed out after " + fmt.Sprint(timeout.Milliseconds()) + "ms[-:-:-]")
 }
 
 // Update the line-position element based on the current
@@ -64,11 +103,22 @@ func (tui *TUI) UpdateScrollPosition() {
 	tui.linePosition.height = height
 	lineCount := tui.linePosition.lineCount
 
-	endRow := math.Min(float64(row+height-1), float64(lineCount))
+	endRow := math.Min(float64(row+height), float64(lineCount))
+
+	// rows and lines differ when long lines wrap, so count the lines on screen where we can
+	if first, last, ok := tui.VisibleLines(); ok {
+		row = first
+		endRow = float64(last + 1)
+	}
 
 	rowStr := fmt.Sprint(row + 1)         // lines are normally one-indexed
 	endRowStr := fmt.Sprint(endRow)       // the last line shown in the buffer
-	lineCountStr := fmt.Sprint(lineCount) // the total line count produced by standard-output last execution
+	lineCountStr := fmt.Sprint(lineCount) // the number of lines of standard-output kept from the last execution
+
+	// output past the store's limits is discarded; show how many lines were written too
+	if written := tui.stdoutViewer.selection.Written(); written > lineCount {
+		lineCountStr += " of " + fmt.Sprint(written)
+	}
 
 	var percentStr = ""
 
@@ -80,6 +130,12 @@ func (tui *TUI) UpdateScrollPosition() {
 		percentStr = fmt.Sprint(math.Round(1_000.0*ratio)/10.0) + "%"
 	}
 
+	// while searching, the match position replaces the percentage
+	if search := &tui.stdoutViewer.search; search.query != "" {
+		tui.linePosition.tview.SetText("[yellow]" + search.Position() + "[-:-:-]  line " + rowStr + "-" + endRowStr + " / " + lineCountStr)
+		return
+	}
+
 	tui.linePosition.tview.SetText("line " + rowStr + "-" + endRowStr + " / " + lineCountStr + "    [blue]" + percentStr + "[blue]")
 }
 
@@ -105,8 +161,7 @@ func (tui *TUI) Draw() {
 }
 
 // Update the line-count based on stdout
-func (tui *TUI) SetLineCount(stdoutBuffer *bytes.Buffer) {
-	count := LineCounter(stdoutBuffer) // TODO this does not work reliably
+func (tui *TUI) SetLineCount(count int) {
 	tui.linePosition.lineCount = count
 
 	// clear if empty
@@ -130,20 +185,130 @@ func (tui *TUI) SetStdoutViewerFocus() {
 
 	// show a blue label, to make it obvious we switched mode
 	tui.commandInput.tview.SetLabelColor(tcell.ColorBlue)
+	for _, input := range tui.fieldInputs {
+		input.tview.SetLabelColor(tcell.ColorGray)
+	}
 }
 
 // Focus on input
 func (tui *TUI) SetInputFocus() {
-	tui.app.tview.SetFocus(tui.commandInput.tview)
-	tui.commandInput.tview.SetLabelColor(tcell.ColorRed)
+	tui.app.tview.SetFocus(tui.FocusedInput().tview)
+
+	// with several fields, only the focused field's label is red
+	for _, input := range tui.Inputs() {
+		if input == tui.FocusedInput() {
+			input.tview.SetLabelColor(tcell.ColorRed)
+		} else {
+			input.tview.SetLabelColor(tcell.ColorGray)
+		}
+	}
 }
 
-func (tui *TUI) ScrollHistoryBack() {
+// List every input field, starting with commandInput
+func (tui *TUI) Inputs() []*TUICommandInput {
+	return append([]*TUICommandInput{tui.commandInput}, tui.fieldInputs...)
+}
 
+// Get the input field being edited
+func (tui *TUI) FocusedInput() *TUICommandInput {
+	return tui.Inputs()[tui.focusedField]
 }
 
-func (tui *TUI) ScrollHistoryForward() {
+// Move the focus forwards or backwards through the input fields, wrapping around
+func (tui *TUI) CycleField(offset int) {
+	count := len(tui.Inputs())
+	tui.focusedField = ((tui.focusedField+offset)%count + count) % count
 
+	tui.SetInputFocus()
+}
+
+// Show a mode's prompt on the first input. With several fields, the prompt also names the first field
+func (tui *TUI) SetPrompt(prompt string) {
+	if tui.ctx.fields != nil && prompt != PROMPT_CMD {
+		prompt = strings.Replace(prompt, "| ", "| "+tui.ctx.fields[0]+" ", 1)
+	}
+
+	tui.commandInput.tview.SetLabel(prompt)
+}
+
+// Run the command a final time and exit, when enter is pressed
+func (tui *TUI) RunFinalCommand() {
+	// the final run happens straight away; drop any pending preview run
+	tui.scheduler.Cancel()
+
+	// an input chosen from history wasn't recorded as it was scrolled past; record it now it's being run
+	if tui.history.Navigated() {
+		tui.history.Reset()
+		tui.RecordInput()
+	}
+
+	tui.state.lineBuffer.SetDone()
+	tui.RunCommand()
+}
+
+// Run the command with the current input, stopping any command that's already running
+func (tui *TUI) RunCommand() {
+	// with no command, standard-input is filtered in-process instead
+	if tui.ctx.filter != "" {
+		tui.RunFilter()
+		return
+	}
+
+	state, _ := tui.state.HandleUserUpdate(tui)
+	tui.state.run = state.run
+
+	tui.UpdatePreview()
+}
+
+// Update the header to show what will run with the current input
+func (tui *TUI) UpdatePreview() {
+	if tui.ctx.filter != "" {
+		tui.commandPreview.UpdateFilterText(tui.ctx.filter, tui.state.lineBuffer)
+		return
+	}
+
+	tui.commandPreview.UpdateText(*tui.ctx.execute, tui.state.lineBuffer, &tui.ctx.envVars)
+}
+
+// Replace the input with text from history. This still runs the command, but
+// doesn't count as the user editing their draft, and isn't recorded in history again
+func (tui *TUI) SetHistoryInput(text string) {
+	tui.history.navigating = true
+	tui.commandInput.tview.SetText(text)
+	tui.history.navigating = false
+}
+
+// Save the current input to history, so it can be navigated, searched, and rerun later
+func (tui *TUI) RecordInput() {
+	hist := History{
+		Input:      tui.state.lineBuffer.content,
+		Command:    tui.ctx.CommandFor(tui.state.lineBuffer),
+		Template:   *tui.ctx.execute,
+		Time:       time.Now(),
+		DangerZone: tui.ctx.dangerZone,
+		Argv:       tui.ctx.argv,
+		FieldNames: tui.ctx.fields,
+		Fields:     append([]string(nil), tui.state.lineBuffer.fields...),
+	}
+	tui.history.Record(hist)
+
+	if tui.cfg.Config.SaveHistory {
+		tui.chans.history <- &hist
+	}
+}
+
+// Show the previous input from history
+func (tui *TUI) ScrollHistoryBack() {
+	if text, ok := tui.history.Back(tui.commandInput.tview.GetText()); ok {
+		tui.SetHistoryInput(text)
+	}
+}
+
+// Show the next input from history, or the user's draft once we run out
+func (tui *TUI) ScrollHistoryForward() {
+	if text, ok := tui.history.Forward(); ok {
+		tui.SetHistoryInput(text)
+	}
 }
 
 // Store RL's TUI
@@ -154,17 +319,85 @@ func (tui *TUI) Stop() {
 // this is not very readable; here are the AddItem definitions
 // (p tview.Primitive, row int, column int, rowSpan int, colSpan int, minGridHeight int, minGridWidth int, focus bool) *tview.Grid
 func (tui *TUI) Grid() *tview.Grid {
+	// standard-error is shown below standard-output, and takes no space when hidden
+	tui.outputPanes = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(tui.stdoutViewer.tview, 0, 1, false).
+		AddItem(tui.stderrViewer.tview, tui.StderrRows(), 0, false)
+
 	return tview.NewGrid().
 		SetRows(COMMAND_AND_LINE_ROWS, STDOUT_ROWS, SPACE_ROWS, HELP_ROWS, COMMAND_ROWS).
-		SetColumns(-14, -6, -1).SetBorders(false).
+		SetColumns(-14, -6, LATENCY_COLUMNS).SetBorders(false).
 		// add each item in a grid
 		AddItem(tui.commandPreview.tview, ROW_0, COL_0, ROWSPAN_1, COLSPAN_1, MINWIDTH_0, MINHEIGHT_0, DONT_FOCUS).
 		AddItem(tui.linePosition.tview, ROW_0, COL_1, ROWSPAN_1, COLSPAN_1, MINWIDTH_0, MINHEIGHT_0, DONT_FOCUS).
 		AddItem(tui.latency.tview, ROW_0, COL_2, ROWSPAN_1, COLSPAN_1, MINWIDTH_0, MINHEIGHT_0, DONT_FOCUS).
-		AddItem(tui.stdoutViewer.tview, ROW_1, COL_0, ROWSPAN_1, COLSPAN_3, MINWIDTH_0, MINHEIGHT_0, DONT_FOCUS).
+		AddItem(tui.outputPanes, ROW_1, COL_0, ROWSPAN_1, COLSPAN_3, MINWIDTH_0, MINHEIGHT_0, DONT_FOCUS).
 		AddItem(tview.NewTextView(), ROW_2, COL_0, ROWSPAN_1, COLSPAN_3, MINWIDTH_1, MINHEIGHT_0, DONT_FOCUS).
 		AddItem(tui.helpBar.tview, ROW_3, COL_0, ROWSPAN_1, COLSPAN_3, MINWIDTH_1, MINHEIGHT_0, DONT_FOCUS).
-		AddItem(tui.commandInput.tview, ROW_4, COL_0, ROWSPAN_1, COLSPAN_3, MINWIDTH_0, MINHEIGHT_0, FOCUS)
+		AddItem(tui.InputRow(), ROW_4, COL_0, ROWSPAN_1, COLSPAN_3, MINWIDTH_0, MINHEIGHT_0, FOCUS)
+}
+
+// The bottom row of rl; the input, or each input field side-by-side
+func (tui *TUI) InputRow() tview.Primitive {
+	if len(tui.fieldInputs) == 0 {
+		return tui.commandInput.tview
+	}
+
+	row := tview.NewFlex()
+	for _, input := range tui.Inputs() {
+		row.AddItem(input.tview, 0, 1, input == tui.commandInput)
+	}
+
+	return row
+}
+
+// How tall the standard-error pane should be; it's hidden when there's nothing to show, when
+// the user has hidden it, and in help-mode
+func (tui *TUI) StderrRows() int {
+	pane := tui.stderrViewer
+
+	if !pane.visible || tui.mode == HelpMode || pane.lineCount == 0 {
+		return 0
+	}
+
+	if pane.lineCount > STDERR_MAX_ROWS {
+		return STDERR_MAX_ROWS
+	}
+
+	return pane.lineCount
+}
+
+// Resize the standard-error pane to fit its content
+func (tui *TUI) LayoutStderr() {
+	if tui.outputPanes == nil {
+		return
+	}
+
+	tui.outputPanes.ResizeItem(tui.stderrViewer.tview, tui.StderrRows(), 0)
+}
+
+// Show the standard-error of the last command to finish
+func (tui *TUI) SetStderr(stderr []byte) {
+	pane := tui.stderrViewer
+
+	pane.tview.Clear()
+	tview.ANSIWriter(pane.tview).Write(stderr)
+
+	pane.lineCount = bytes.Count(stderr, []byte("\n"))
+	if len(stderr) > 0 && stderr[len(stderr)-1] != '\n' {
+		// count a last line that's missing its newline
+		pane.lineCount++
+	}
+
+	pane.tview.ScrollToBeginning()
+	tui.LayoutStderr()
+}
+
+// Show or hide the standard-error pane
+func (tui *TUI) ToggleStderr() {
+	tui.stderrViewer.visible = !tui.stderrViewer.visible
+	tui.LayoutStderr()
 }
 
 // Start RL's TUI, and handle failures
@@ -173,8 +406,13 @@ func (tui *TUI) Start() int {
 
 	grid := tui.Grid()
 
+	// overlays like history-search are shown on top of the main grid
+	tui.pages = tview.NewPages().
+		AddPage(PAGE_MAIN, grid, true, true).
+		AddPage(PAGE_HISTORY_SEARCH, tui.historySearch.tview, true, false)
+
 	// start the tview application
-	if err := tui.app.tview.SetRoot(grid, true).SetFocus(grid).Run(); err != nil {
+	if err := tui.app.tview.SetRoot(tui.pages, true).SetFocus(grid).Run(); err != nil {
 		fmt.Printf("RL: Application crashed! %v", err)
 		return 1
 	}
@@ -182,29 +420,102 @@ func (tui *TUI) Start() int {
 	return <-tui.chans.exitCode
 }
 
-//  The preview element showing a preview of the command that will be executed
+// The preview element showing a preview of the command that will be executed
 type TUICommandPreview struct {
-	tview *tview.TextView
+	tview       *tview.TextView
+	dangerZone  bool     // show a warning that safety checks are disabled
+	placeholder string   // the text in the command replaced with the user's input
+	fieldVars   []string // how the command refers to each input field, e.g $PATTERN
+}
+
+// The colours each input field is highlighted with in the header, in the order fields are declared
+var INPUT_FIELD_COLORS = []string{"red", "green", "yellow", "fuchsia", "aqua"}
+
+// The start of the header; a warning banner is always shown when safety checks are disabled
+func (prev *TUICommandPreview) Prefix() string {
+	if prev.dangerZone {
+		return DANGER_ZONE_BANNER + " rl: "
+	}
+
+	return "rl: "
 }
 
 type TUILatencyViewer struct {
 	tview *tview.TextView
 }
 
+// Replace each variable in a command in a single pass, longest name first. Names ending in an identifier
+// character only match whole, so $GLOB isn't found in $GLOBAL; and replacements aren't searched again
+func ReplaceVars(command string, replacements map[string]string) string {
+	names := []string{}
+	for name := range replacements {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(left, right int) bool {
+		return len(names[left]) > len(names[right])
+	})
+
+	isIdentifier := func(char byte) bool {
+		return char == '_' || char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9'
+	}
+
+	var replaced strings.Builder
+
+	for idx := 0; idx < len(command); {
+		matched := ""
+
+		for _, name := range names {
+			end := idx + len(name)
+
+			if name == "" || !strings.HasPrefix(command[idx:], name) {
+				continue
+			}
+
+			if isIdentifier(name[len(name)-1]) && end < len(command) && isIdentifier(command[end]) {
+				continue
+			}
+
+			matched = name
+			break
+		}
+
+		if matched == "" {
+			replaced.WriteByte(command[idx])
+			idx++
+		} else {
+			replaced.WriteString(replacements[matched])
+			idx += len(matched)
+		}
+	}
+
+	return replaced.String()
+}
+
 // Update the UI header based on user input
 func (prev *TUICommandPreview) UpdateText(command string, buffer *LineBuffer, envVars *[][]string) {
-
-	summary := strings.ReplaceAll(command, "$"+ENVAR_NAME_RL_INPUT, "[red]"+buffer.content+"[default]")
+	replacements := map[string]string{}
 
 	for _, pair := range *envVars {
 		varName := "$" + pair[0]
 		// it might be nice to show env-vars, but these can contain passwords. This is a saner default.
-		highlight := "[blue]" + varName + "[default]"
+		replacements[varName] = "[blue]" + varName + "[default]"
+	}
 
-		summary = strings.ReplaceAll(summary, varName, highlight)
+	// highlight each field in its own colour
+	for idx, fieldVar := range prev.fieldVars {
+		color := INPUT_FIELD_COLORS[idx%len(INPUT_FIELD_COLORS)]
+		replacements[fieldVar] = "[" + color + "]" + buffer.fields[idx] + "[default]"
 	}
 
-	prev.tview.SetText("rl: " + "[::r]" + summary + "[-:-:-]")
+	replacements[prev.placeholder] = "[red]" + buffer.content + "[default]"
+	summary := ReplaceVars(command, replacements)
+
+	prev.tview.SetText(prev.Prefix() + "[::r]" + summary + "[-:-:-]")
+}
+
+// Update the UI header to show the filter query, in filter mode
+func (prev *TUICommandPreview) UpdateFilterText(filter string, buffer *LineBuffer) {
+	prev.tview.SetText(prev.Prefix() + "[::r]filter " + filter + ": [red]" + tview.Escape(buffer.content) + "[-:-:-]")
 }
 
 // A component for the line-position in the stdout viewer
@@ -218,13 +529,39 @@ type TUILinePosition struct {
 
 // A component for the stdout viewer
 type TUITextViewer struct {
-	tview       *tview.TextView
-	withDefault bool
+	tview          *tview.TextView
+	withDefault    bool
+	selection      OutputSelection // the output lines, and which are chosen in view mode
+	search         OutputSearch    // the text searched for in the output, and its matches
+	highlightInput string          // how the current input is highlighted in the output; off, literal, or regex
+	wrap           bool            // are long lines wrapped?
+	count          int             // the count typed before a navigation key, e.g 12 in 12g; zero when none was typed
+	width          int             // the viewer's width when its size was last checked
+	height         int             // the viewer's height when its size was last checked
+}
+
+// Has the viewer changed size since this was last checked?
+func (viewer *TUITextViewer) Resized() bool {
+	_, _, width, height := viewer.tview.GetInnerRect()
+	resized := width != viewer.width || height != viewer.height
+
+	viewer.width = width
+	viewer.height = height
+
+	return resized
+}
+
+// A component showing the standard-error of the last command, below its standard-output
+type TUIStderrViewer struct {
+	tview     *tview.TextView
+	visible   bool // has the user chosen to show standard-error?
+	lineCount int  // the number of lines of standard-error
 }
 
 // A component for the RL text-input field
 type TUICommandInput struct {
 	tview *tview.InputField
+	field int // which input field this is; the first holds $RL_INPUT
 }
 
 type TUIHelpBar struct {
@@ -236,18 +573,34 @@ func (tui *TUI) SetMode(mode PromptMode) {
 	currMode := tui.mode
 	tui.mode = mode
 
+	// the standard-error pane is hidden while help is shown
+	tui.LayoutStderr()
+
+	if currMode == CommandMode && mode != CommandMode {
+		// the input held a command; put back what the user had typed
+		tui.commandInput.tview.SetText(tui.state.lineBuffer.content)
+	}
+
 	if mode == EditMode {
 		// EditMode switches
-		tui.helpBar.tview.SetText(HELP_EDIT)
-		tui.commandInput.tview.SetLabel(PROMPT_EDIT)
+		tui.helpBar.tview.SetText(tui.keys.HelpBar(EditMode))
+		tui.SetPrompt(PROMPT_EDIT)
 		tui.SetInputFocus()
+
+		if currMode == ViewMode {
+			// hide the markers used to choose lines
+			tui.RenderOutput()
+		}
 	} else if mode == ViewMode {
 		// Viewmode switches
 
-		tui.helpBar.tview.SetText(HELP_VIEW)
-		tui.commandInput.tview.SetLabel(PROMPT_VIEW)
+		tui.helpBar.tview.SetText(tui.keys.HelpBar(ViewMode))
+		tui.SetPrompt(PROMPT_VIEW)
 		tui.SetStdoutViewerFocus()
 
+		// show the cursor and markers used to choose lines
+		tui.RenderOutput()
+
 		if currMode == HelpMode {
 			// update the line-count in the buffer after switching
 			tui.UpdateScrollPosition()
@@ -256,29 +609,35 @@ func (tui *TUI) SetMode(mode PromptMode) {
 		// Helpmode switches
 
 		// TODO await lock to prevent clash with slow-writing command. Or just kill command
+		tui.scheduler.Cancel()
 		tui.state.StopProcess()
 
 		// TODO update line-count
 
-		tui.helpBar.tview.SetText(HELP_HELP)
-		tui.commandPreview.tview.SetText("rl")
-		tui.stdoutViewer.tview.SetText(HelpDocumentation)
+		tui.helpBar.tview.SetText(tui.keys.HelpBar(HelpMode))
+		tui.commandPreview.tview.SetText(tui.commandPreview.Prefix())
+		tui.stdoutViewer.tview.SetText(HelpDocumentation(tui.keys))
 		tui.commandInput.tview.SetLabelColor(tcell.ColorGreen)
-		tui.commandInput.tview.SetLabel(PROMPT_HELP)
+		tui.SetPrompt(PROMPT_HELP)
 	} else if mode == CommandMode {
-		tui.helpBar.tview.SetText(HELP_COMMAND)
-		tui.commandInput.tview.SetLabel(PROMPT_CMD)
+		tui.helpBar.tview.SetText(tui.keys.HelpBar(CommandMode))
+		tui.SetPrompt(PROMPT_CMD)
+		tui.commandInput.tview.SetText("")
+		tui.app.tview.SetFocus(tui.commandInput.tview)
+		tui.commandInput.tview.SetLabelColor(tcell.ColorYellow)
 	}
 }
 
 // Create the command-preview element; this will show what the user is actually executing
-func NewCommandPreview(execute *string) *TUICommandPreview {
+func NewCommandPreview(execute *string, dangerZone bool, placeholder string, fieldVars []string) *TUICommandPreview {
 	part := tview.NewTextView().
 		SetTextColor(tcell.ColorDefault).
-		SetText("rl: " + "[::r]" + *execute + "[-:-:-]").
 		SetDynamicColors(true)
 
-	return &TUICommandPreview{part}
+	prev := &TUICommandPreview{part, dangerZone, placeholder, fieldVars}
+	part.SetText(prev.Prefix() + "[::r]" + *execute + "[-:-:-]")
+
+	return prev
 }
 
 // Create a header widget that shows the current scroll position in
@@ -305,21 +664,32 @@ func NewLatencyViewer() *TUILatencyViewer {
 func NewRLApp(tui *TUI) *TUIApp {
 	// -- declare Tview application --
 	app := tview.NewApplication()
-	// -- we actually want to do the opposite; this prevents mousewheel scroll from breaking things.
-	app.EnableMouse(true)
-	app.SetMouseCapture(func(event *tcell.EventMouse, action tview.MouseAction) (*tcell.EventMouse, tview.MouseAction) {
-		return nil, 0
+	// with the mouse disabled, the terminal selects text as normal
+	app.EnableMouse(!tui.cfg.Config.DisableMouse)
+	app.SetMouseCapture(tui.HandleMouse)
+
+	// output is only rendered for the rows on screen; render it again once the viewer is resized
+	app.SetAfterDrawFunc(func(screen tcell.Screen) {
+		if tui.stdoutViewer.Resized() {
+			go app.QueueUpdateDraw(func() {
+				tui.RenderOutput()
+				tui.UpdateScrollPosition()
+			})
+		}
 	})
 
-	// if key-event filtering is needed, it can be applied here
+	// keys are looked up in the bindings for the current mode, before the focused element sees them
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		// Ctrl + P is intercepted by VSCode; Ctrl + O is the next best thing
-		if event.Key() == tcell.KeyCtrlO {
-			// TODO
+		// the history-search overlay handles its own keys
+		if tui.historySearch.open {
+			if event.Key() == tcell.KeyCtrlC {
+				tui.Quit()
+				return nil
+			}
+			return event
 		}
-		if event.Key() == tcell.KeyCtrlC {
-			tui.Stop()
-			tui.chans.exitCode <- 0
+
+		if tui.HandleKey(event) {
 			return nil
 		}
 		return event
@@ -340,43 +710,13 @@ func NewTextViewer(tui *TUI) *TUITextViewer {
 			// TODO
 		}
 
-		switch event.Rune() {
-		case ':':
-			tui.SetMode(CommandMode)
-			return nil
-		case '?':
-			tui.SetMode(HelpMode)
-			return nil
-		case '/':
-			tui.SetMode(EditMode)
-			return nil
-		case 'g', 'G':
-			// TODO broken and dumb.
-
-			tui.UpdateScrollPosition()
-			return event
-		}
-
+		// keys without a binding scroll help-mode, or output that isn't from a command
 		switch event.Key() {
 		case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
 			tui.UpdateScrollPosition()
 			return event
 		}
 
-		if event.Key() == tcell.KeyEscape || event.Rune() == 'q' {
-			if tui.mode == HelpMode {
-				// quit back to view-mode
-				tui.SetMode(ViewMode)
-				// update the line-count
-				tui.UpdateScrollPosition()
-				return nil
-			} else {
-				tui.Stop()
-				tui.chans.exitCode <- 0
-				return nil
-			}
-		}
-
 		return event
 	}
 
@@ -385,59 +725,51 @@ func NewTextViewer(tui *TUI) *TUITextViewer {
 	return &TUITextViewer{
 		tview:       part,
 		withDefault: true,
+		wrap:        true,
 	}
 }
 
-func NewCommandInput(tui *TUI) *TUICommandInput {
-	ctx := tui.ctx
-	cfg := tui.cfg
-	state := *tui.state
-	execute := ctx.execute
+func NewStderrViewer() *TUIStderrViewer {
+	part := tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextColor(tcell.ColorRed)
 
-	// When the input is "done" according to tview is when KeyEnter, KeyEscape,
-	// KeyTab, KeyDown, KeyUp, KeyBacktab are entered. We can wire custom behaviours into these
-	// rather than just terminate the entire app! Normally, done means switch to view mode.
-	onDone := func(key tcell.Key) {
-		switch key {
-		case tcell.KeyEnter:
-			tui.state.lineBuffer.SetDone()
-			state, _ = state.HandleUserUpdate(tui)
-		case tcell.KeyUp:
-			tui.ScrollHistoryBack()
-			//tui.SetMode(ViewMode)
-			//tui.UpdateScrollPosition()
-		case tcell.KeyDown:
-			tui.ScrollHistoryForward()
-
-			//tui.SetMode(ViewMode)
-			//tui.UpdateScrollPosition()
-		case tcell.KeyEscape:
-			tui.SetMode(ViewMode)
-		}
-	}
+	return &TUIStderrViewer{part, true, 0}
+}
 
+func NewCommandInput(tui *TUI) *TUICommandInput {
 	run := false
+	lastText := ""
 
 	// TODO implement ctrl+left, ctrl+right
 	onChange := func(text string) {
+		// command-mode input is run by rl itself, on enter
+		if tui.mode == CommandMode {
+			return
+		}
+
+		// setting text from a key-handler (e.g history) reports the same change twice; only run once
+		if run && text == lastText {
+			return
+		}
+		lastText = text
+
 		if !run {
 			tui.stdoutViewer.tview.SetTextAlign(tview.AlignLeft)
 			run = true
 		}
 
-		state.lineBuffer.content = text
-		state, _ = state.HandleUserUpdate(tui)
-
-		if cfg.Config.SaveHistory {
-			tui.chans.history <- &History{
-				Input:    text,
-				Command:  SubstitueCommand(execute, &text),
-				Template: *execute,
-				Time:     time.Now(),
-			}
+		tui.state.lineBuffer.content = text
+		if tui.ctx.fields != nil {
+			tui.state.lineBuffer.fields[0] = text
 		}
+		tui.ScheduleCommand()
 
-		tui.commandPreview.UpdateText(*execute, state.lineBuffer, &ctx.envVars)
+		// inputs scrolled past in history are already saved; only typing, which starts a new draft, is recorded
+		if !tui.history.navigating {
+			tui.history.Reset()
+			tui.RecordInput()
+		}
 	}
 
 	commandInput := tview.NewInputField()
@@ -445,19 +777,36 @@ func NewCommandInput(tui *TUI) *TUICommandInput {
 	commandInput.
 		SetLabelColor(tcell.ColorRed).
 		SetChangedFunc(onChange).
-		SetLabel(PROMPT_EDIT).
-		SetDoneFunc(onDone).
 		Focus(func(self tview.Primitive) {
 			tui.InvertCommandInput()
 		})
 
-	return &TUICommandInput{commandInput}
+	return &TUICommandInput{commandInput, 0}
+}
+
+// Create an input for the second or later field given with -f. Editing it re-runs the command
+// with the field's environment-variable set to its text
+func NewFieldInput(tui *TUI, field int) *TUICommandInput {
+	onChange := func(text string) {
+		tui.state.lineBuffer.fields[field] = text
+
+		tui.stdoutViewer.tview.SetTextAlign(tview.AlignLeft)
+		tui.ScheduleCommand()
+		tui.RecordInput()
+	}
+
+	input := tview.NewInputField().
+		SetLabel(" " + tui.ctx.fields[field] + " > ").
+		SetLabelColor(tcell.ColorGray).
+		SetChangedFunc(onChange)
+
+	return &TUICommandInput{input, field}
 }
 
 func NewHelpBar(tui *TUI) *TUIHelpBar {
 	view := tview.NewTextView().
 		SetDynamicColors(true).
-		SetText(HELP_EDIT)
+		SetText(tui.keys.HelpBar(EditMode))
 
 	return &TUIHelpBar{view}
 }
@@ -476,15 +825,50 @@ func NewUI(state LineChangeState, cfg *ConfigOpts, ctx *LineChangeCtx, histChan
 	tui.chans.history = histChan
 	tui.chans.exitCode = make(chan int, 100)
 
+	tui.history = NewHistoryCursor(cfg.HistoryPath, *execute)
+
+	// validated when RL starts
+	tui.keys, _ = NewKeyBindings(cfg.Config.Keys)
+
 	tui.app = NewRLApp(&tui)
+	tui.scheduler = NewCommandScheduler(cfg, func(fn func()) {
+		tui.app.tview.QueueUpdateDraw(fn)
+	})
+	tui.filter = NewStdinFilter(ctx.stdin)
 	tui.latency = NewLatencyViewer()
-	tui.commandPreview = NewCommandPreview(execute)
+	tui.commandPreview = NewCommandPreview(execute, ctx.dangerZone, ctx.Placeholder(), ctx.FieldVars())
 	tui.linePosition = NewLinePosition()
 	tui.stdoutViewer = NewTextViewer(&tui)
+	tui.stdoutViewer.highlightInput = HIGHLIGHT_OFF
+	if cfg.Config.HighlightInput != "" {
+		tui.stdoutViewer.highlightInput = cfg.Config.HighlightInput
+	}
+	tui.stderrViewer = NewStderrViewer()
 	tui.commandInput = NewCommandInput(&tui)
+	for field := 1; field < len(ctx.fields); field++ {
+		tui.fieldInputs = append(tui.fieldInputs, NewFieldInput(&tui, field))
+	}
+	tui.SetPrompt(PROMPT_EDIT)
 	tui.helpBar = NewHelpBar(&tui)
+	tui.historySearch = NewHistorySearch(&tui)
 
 	tui.InvertCommandInput()
 
+	// pre-fill the inputs when rerunning a previous session; this runs the command straight away
+	for _, input := range tui.fieldInputs {
+		if text := state.lineBuffer.fields[input.field]; text != "" {
+			input.tview.SetText(text)
+		}
+	}
+
+	if content := state.lineBuffer.content; content != "" {
+		tui.commandInput.tview.SetText(content)
+	}
+
+	if ctx.filter != "" {
+		tui.UpdatePreview()
+		tui.StartFilterRefresh()
+	}
+
 	return &tui
 }
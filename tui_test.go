@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestReplaceVars(t *testing.T) {
+	replacements := map[string]string{"$GLOB": "<glob>", "$RL_INPUT": "<input>", "{}": "<arg>"}
+
+	cases := []struct {
+		command  string
+		expected string
+	}{
+		{`rg "$RL_INPUT" -g "$GLOB"`, `rg "<input>" -g "<glob>"`},
+		{`echo "$GLOBAL" "$RL_INPUT_X"`, `echo "$GLOBAL" "$RL_INPUT_X"`},
+		{`echo $GLOB.txt $GLOB-1`, `echo <glob>.txt <glob>-1`},
+		{`echo x{}y`, `echo x<arg>y`},
+		{`echo`, `echo`},
+	}
+
+	for _, testCase := range cases {
+		if actual := ReplaceVars(testCase.command, replacements); actual != testCase.expected {
+			t.Errorf("%q: expected %q, got %q", testCase.command, testCase.expected, actual)
+		}
+	}
+
+	// replacements aren't replaced again
+	if actual := ReplaceVars(`echo $A`, map[string]string{"$A": "$B", "$B": "no"}); actual != `echo $B` {
+		t.Errorf("expected a replacement not to be replaced again, got %q", actual)
+	}
+}
@@ -1,18 +1,16 @@
 package main
 
 import (
-	"bufio"
-	"os"
-	"os/exec"
 	"time"
 
 	"github.com/smallnest/ringbuffer"
 )
 
-//  Stores user-input text, and whether a terminal character has been reached.
+// Stores user-input text, and whether a terminal character has been reached.
 type LineBuffer struct {
-	content string // The user-entered character?
-	done    bool   // Has a terminal character been reached?
+	content string   // The user-entered character?
+	done    bool     // Has a terminal character been reached?
+	fields  []string // The text of each named input field, in the order they were declared; the first is also content
 }
 
 func (buff *LineBuffer) SetDone() *LineBuffer {
@@ -24,62 +22,87 @@ func (buff *LineBuffer) SetDone() *LineBuffer {
 // and commands are executed.
 type LineChangeState struct {
 	lineBuffer   *LineBuffer // a pointer to an array of characters the user has entered into this application, excluding some special characters like backspaces.
-	cmd          *exec.Cmd   // a pointer to the command currently being executed, if rl is running in execute mode
+	run          *CommandRun // the command currently being executed, if rl is running in execute mode
 	commandStart time.Time   // when was the command launched?
 }
 
 // Contextual contantish information like the user's shell, environmental variables, and command-line options
 type LineChangeCtx struct {
 	shell       string                 // the user's shell-variable
+	profile     ShellProfile           // how to run a script in the user's shell
 	inputOnly   bool                   // should we only return the user's input (e.g lineBuffer) instead of the final command execution, if we're running in execute mode?
 	execute     *string                // a string to execute in a user's shell
 	environment []string               // an array of this processes environmental variables
 	envVars     [][]string             // an array of envar-name mappings to string-values
 	stdin       *ringbuffer.RingBuffer // a buffer containing as much stdin as we are willing to store
+	dangerZone  bool                   // has the user disabled rl's safety checks with --danger-zone?
+	argv        []string               // the command to run without a shell, in --exec mode; nil when commands run in the user's shell
+	fields      []string               // the names of the input fields given with -f; nil when there's just $RL_INPUT
+	filter      string                 // how piped standard-input is filtered when no command was given; empty otherwise
 }
 
 // RL Configuration structure
 type ConfigOpts struct {
 	HistoryPath string       // the history path for RL
 	ConfigPath  string       // the config path for RL
+	SavedPath   string       // the path to sessions saved from command-mode
 	Config      RLConfigFile // RL configuration
 }
 
 // RL Configuration file-data
 type RLConfigFile struct {
-	SaveHistory bool `yaml:"save_history"` // A configuration option. Should a history-file be used?
+	SaveHistory        bool              `yaml:"save_history"`          // A configuration option. Should a history-file be used?
+	DebounceMs         int               `yaml:"debounce_ms"`           // How long to wait for typing to pause before running a command, in milliseconds
+	MaxSpawnsPerSecond int               `yaml:"max_spawns_per_second"` // The most commands to start in any one second. Zero is unlimited
+	StopSignal         string            `yaml:"stop_signal"`           // The signal sent to stop a command that's no longer needed
+	StopGraceMs        *int              `yaml:"stop_grace_ms"`         // How long a stopped command has to exit before it's sent SIGKILL; nil when unset, as 0 is allowed
+	Shell              string            `yaml:"shell"`                 // The shell commands run in, overriding $SHELL
+	PreviewPty         bool              `yaml:"preview_pty"`           // Should preview commands run in a pseudo-terminal, rather than with pipes?
+	TimeoutMs          int               `yaml:"timeout_ms"`            // How long a preview command may run before it's stopped. Zero is unlimited
+	FinalTimeoutMs     int               `yaml:"final_timeout_ms"`      // How long the final command may run before it's stopped. Zero is unlimited
+	SelectOnEnter      bool              `yaml:"select_on_enter"`       // Should ENTER in view mode print the line under the cursor, rather than run the command?
+	Actions            map[string]string `yaml:"actions"`               // Commands run against the line under the cursor, by the key that runs them
+	HighlightInput     string            `yaml:"highlight_input"`       // How the current input is highlighted in the output; off, literal, or regex
+	MaxOutputLines     int               `yaml:"max_output_lines"`      // The most lines of a preview command's output to keep. Zero uses the default
+	MaxOutputBytes     int               `yaml:"max_output_bytes"`      // The most bytes of a preview command's output to keep. Zero uses the default
+	DisableMouse       bool              `yaml:"disable_mouse"`         // Should the terminal handle the mouse, so text can be selected as normal?
+	ClipboardCommand   string            `yaml:"clipboard_command"`     // The command text is copied with, instead of OSC 52
+	Keys               KeysConfig        `yaml:"keys"`                  // Key-chords bound to named actions, by mode
 }
 
 // RL History Information
 type History struct {
-	Input     string    `json:"input"`      // The user-entered input text
-	Command   string    `json:"command"`    // The command executed
-	Template  string    `json:"template"`   // The 'template' the user provided to -x
-	Time      time.Time `json:"time"`       // The time the command was started, approximately
-	StartTime time.Time `json:"start_time"` // The start-time of the program, approximately. Can be used as an ID.
+	Input      string    `json:"input"`                 // The user-entered input text
+	Command    string    `json:"command"`               // The command executed
+	Template   string    `json:"template"`              // The 'template' the user provided to -x
+	Time       time.Time `json:"time"`                  // The time the command was started, approximately
+	StartTime  time.Time `json:"start_time"`            // The start-time of the program, approximately. Can be used as an ID.
+	DangerZone bool      `json:"danger_zone,omitempty"` // Was the session run with safety checks disabled?
+	Argv       []string  `json:"argv,omitempty"`        // The command run without a shell, in --exec mode
+	FieldNames []string  `json:"field_names,omitempty"` // The names of the input fields given with -f
+	Fields     []string  `json:"fields,omitempty"`      // The text of each input field, in the order they were declared
 }
 
-type HistoryCursor struct {
-	historyPath string
-	index       int
-	buffer      ringbuffer.RingBuffer
+// A session saved by name from command-mode, which can be reopened with --rerun <name>
+type SavedSession struct {
+	Name       string    `json:"name"`                  // The name the session was saved as
+	Input      string    `json:"input"`                 // The user-entered input text
+	Template   string    `json:"template"`              // The 'template' the user provided
+	Time       time.Time `json:"time"`                  // The time the session was saved
+	Argv       []string  `json:"argv,omitempty"`        // The command run without a shell, in --exec mode
+	FieldNames []string  `json:"field_names,omitempty"` // The names of the input fields given with -f
+	Fields     []string  `json:"fields,omitempty"`      // The text of each input field, in the order they were declared
 }
 
-func (curs *HistoryCursor) GetCount() int {
-	conn, _ := os.Open(curs.historyPath)
-
-	// Create new Scanner.
-	scanner := bufio.NewScanner(conn)
-
-	count := 0
-	for scanner.Scan() {
-		scanner.Text()
-		count += 1
-	}
-
-	return count
-}
-
-func (curs *HistoryCursor) GetHistory(index int) History {
-	return History{}
+// Navigates previously entered inputs, most-recent first. The history file is
+// read into memory once, so scrolling doesn't rescan it on each key-press
+type HistoryCursor struct {
+	historyPath string    // the history file to index
+	template    string    // the template currently being run; inputs for this template are shown first
+	loaded      bool      // has the history file been read yet?
+	entries     []History // every history entry read or recorded this session, oldest first
+	matches     []History // the inputs being navigated, most-recent first
+	index       int       // the position in matches; -1 when the user is editing their own draft
+	draft       string    // what the user was typing before they started navigating
+	navigating  bool      // is the input currently being set by the cursor rather than typed?
 }
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Words that start a command in fish, nushell, or PowerShell, but don't name it
+var COMMAND_KEYWORDS = map[string]bool{
+	"and": true, "or": true, "not": true, "!": true, "if": true, "else": true, "elseif": true, "while": true,
+	"begin": true,
+}
+
+// Shells where a variable starting a command is a value, rather than a command to run
+var EXPRESSION_SHELLS = map[string]bool{
+	"nu": true, "pwsh": true, "powershell": true,
+}
+
+// Words that redirect a command's input or output in fish, nushell, or PowerShell, e.g >, 2>>, or o+e>
+var REDIRECT_PATTERN = regexp.MustCompile(`^(?:[0-9]*|&|\*|o|e|out|err|o\+e|e\+o|out\+err|err\+out)(>>?|<)\??`)
+
+// Is this shell PowerShell? Its commands and variables are case-insensitive, and & runs a command
+func isPowerShell(shell string) bool {
+	return shell == "pwsh" || shell == "powershell"
+}
+
+// A word of a command in a template rl can't parse fully, and where its user-input is
+type scriptWord struct {
+	AuditWord
+	line uint // the line of the word's user-input, or of the word
+	col  uint // the column of the word's user-input, or of the word
+}
+
+// A command in a template rl can't parse fully
+type scriptCommand struct {
+	words []scriptWord
+	piped *scriptWord // the first word holding user-input in the commands piped into this one, if there is one
+}
+
+// The first word holding user-input in a command, or in the commands piped into it
+func (command scriptCommand) firstInput() *scriptWord {
+	if command.piped != nil {
+		return command.piped
+	}
+
+	for idx := range command.words {
+		if command.words[idx].input != "" {
+			return &command.words[idx]
+		}
+	}
+
+	return nil
+}
+
+// Reads a template for fish, nushell, or PowerShell into commands and words. These can't be parsed fully, but
+// quotes, separators, pipes, and substitutions are enough to find what each command runs
+type WordScanner struct {
+	vars       []string        // how the template refers to each variable holding user-input, e.g $env.RL_INPUT
+	caseless   bool            // are variable names case-insensitive?
+	commands   []scriptCommand // the commands read so far
+	command    scriptCommand   // the command being read
+	word       scriptWord      // the word being read
+	inWord     bool            // has the word being read started?
+	outer      []scriptCommand // the commands a substitution is within, innermost last
+	outerWords []scriptWord    // the words a substitution is within, innermost last
+	starts     []int           // where each substitution's commands start in commands, innermost last
+	line       uint            // the line being read
+	col        uint            // the column being read
+}
+
+// Start a word, if one isn't already being read
+func (scanner *WordScanner) startWord() {
+	if !scanner.inWord {
+		scanner.inWord = true
+		scanner.word = scriptWord{AuditWord{literal: true}, scanner.line, scanner.col}
+	}
+}
+
+// Add literal text to the word being read
+func (scanner *WordScanner) addText(text string) {
+	scanner.startWord()
+
+	if scanner.word.literal {
+		scanner.word.text += text
+		scanner.word.prefix += text
+	}
+}
+
+// Add an expansion to the word being read; a variable, or a substitution. The word is no longer literal
+func (scanner *WordScanner) addExpansion() {
+	scanner.startWord()
+	scanner.word.literal = false
+	scanner.word.text = ""
+}
+
+// Add a reference to user-input to the word being read, found at a line and column
+func (scanner *WordScanner) addInput(input string, line uint, col uint) {
+	scanner.addExpansion()
+
+	if scanner.word.input == "" {
+		scanner.word.input = input
+		scanner.word.line = line
+		scanner.word.col = col
+	}
+}
+
+// Finish the word being read
+func (scanner *WordScanner) endWord() {
+	if scanner.inWord {
+		scanner.command.words = append(scanner.command.words, scanner.word)
+		scanner.inWord = false
+	}
+}
+
+// Finish the command being read. If it's piped into the next command, note any user-input flowing into it
+func (scanner *WordScanner) endCommand(piped bool) {
+	scanner.endWord()
+
+	next := scriptCommand{}
+	if piped {
+		next.piped = scanner.command.firstInput()
+	}
+
+	if len(scanner.command.words) > 0 {
+		scanner.commands = append(scanner.commands, scanner.command)
+	}
+
+	scanner.command = next
+}
+
+// Start reading a substitution, e.g (cmd) in fish; it's part of the word being read
+func (scanner *WordScanner) openSubstitution() {
+	scanner.addExpansion()
+
+	scanner.outer = append(scanner.outer, scanner.command)
+	scanner.outerWords = append(scanner.outerWords, scanner.word)
+	scanner.starts = append(scanner.starts, len(scanner.commands))
+
+	scanner.command = scriptCommand{}
+	scanner.inWord = false
+}
+
+// Finish reading a substitution, and carry on with the word it's part of. User-input in the substitution's
+// output is user-input in the word too
+func (scanner *WordScanner) closeSubstitution() {
+	scanner.endCommand(false)
+
+	if len(scanner.outer) == 0 {
+		return
+	}
+
+	last := len(scanner.outer) - 1
+	start := scanner.starts[last]
+
+	scanner.command = scanner.outer[last]
+	scanner.word = scanner.outerWords[last]
+	scanner.inWord = true
+
+	scanner.outer = scanner.outer[:last]
+	scanner.outerWords = scanner.outerWords[:last]
+	scanner.starts = scanner.starts[:last]
+
+	for _, command := range scanner.commands[start:] {
+		if input := command.firstInput(); input != nil {
+			scanner.addInput(input.input, input.line, input.col)
+			break
+		}
+	}
+}
+
+// Find the reference to user-input at the start of some text, e.g $env.RL_INPUT. It must not be followed by
+// more of a variable's name, so $RL_INPUT_X isn't $RL_INPUT
+func (scanner *WordScanner) inputVar(text string) (string, bool) {
+	for _, inputVar := range scanner.vars {
+		if len(text) < len(inputVar) {
+			continue
+		}
+
+		candidate := text[:len(inputVar)]
+		matches := candidate == inputVar || scanner.caseless && strings.EqualFold(candidate, inputVar)
+
+		if !matches {
+			continue
+		}
+
+		if rest := text[len(inputVar):]; rest != "" {
+			next := []rune(rest)[0]
+			if next == '_' || unicode.IsLetter(next) || unicode.IsDigit(next) {
+				continue
+			}
+		}
+
+		return inputVar, true
+	}
+
+	return "", false
+}
+
+// Split a template for fish, nushell, or PowerShell into commands and their words
+func ScanWords(template string, vars []string, powershell bool) []scriptCommand {
+	scanner := &WordScanner{vars: vars, caseless: powershell}
+	runes := []rune(template)
+
+	// the line and column of each character
+	lines := make([]uint, len(runes))
+	cols := make([]uint, len(runes))
+
+	line, col := uint(1), uint(1)
+	for idx, char := range runes {
+		lines[idx], cols[idx] = line, col
+
+		if char == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+
+	quote := rune(0)
+
+	for idx := 0; idx < len(runes); idx++ {
+		char := runes[idx]
+		scanner.line, scanner.col = lines[idx], cols[idx]
+
+		next := rune(0)
+		if idx+1 < len(runes) {
+			next = runes[idx+1]
+		}
+
+		switch {
+		case quote == '\'':
+			if char == '\'' {
+				quote = 0
+			} else {
+				scanner.addText(string(char))
+			}
+		case char == '$':
+			if inputVar, ok := scanner.inputVar(string(runes[idx:])); ok {
+				scanner.addInput(inputVar, scanner.line, scanner.col)
+				idx += len([]rune(inputVar)) - 1
+			} else {
+				scanner.addExpansion()
+			}
+		case quote == '"':
+			if char == '"' {
+				quote = 0
+			} else if char == '\\' && next != 0 {
+				scanner.addText(string(next))
+				idx++
+			} else {
+				scanner.addText(string(char))
+			}
+		case char == '\'' || char == '"':
+			scanner.startWord()
+			quote = char
+		case char == '\\' && next != 0:
+			scanner.addText(string(next))
+			idx++
+		case char == ' ' || char == '\t' || char == '\r':
+			scanner.endWord()
+		case char == '\n' || char == ';':
+			scanner.endCommand(false)
+		case char == '|' && next == '|', char == '&' && next == '&':
+			scanner.endCommand(false)
+			idx++
+		case char == '|':
+			scanner.endCommand(true)
+		case char == '&' && powershell && !scanner.inWord:
+			// PowerShell's call operator; it runs the command that follows it
+			scanner.addText("&")
+		case char == '&' && !scanner.inWord:
+			scanner.endCommand(false)
+		case char == '(':
+			scanner.openSubstitution()
+		case char == ')':
+			scanner.closeSubstitution()
+		case (char == '{' || char == '}') && !scanner.inWord:
+			scanner.endCommand(false)
+		case char == '#' && !scanner.inWord:
+			for idx+1 < len(runes) && runes[idx+1] != '\n' {
+				idx++
+			}
+		default:
+			scanner.addText(string(char))
+		}
+	}
+
+	for len(scanner.outer) > 0 {
+		scanner.closeSubstitution()
+	}
+	scanner.endCommand(false)
+
+	return scanner.commands
+}
+
+// Remove redirections from a command's words, and find user-input naming a file that is written to
+func auditRedirects(words []scriptWord) ([]scriptWord, []AuditFinding) {
+	kept := []scriptWord{}
+	findings := []AuditFinding{}
+
+	for idx := 0; idx < len(words); idx++ {
+		word := words[idx]
+
+		match := REDIRECT_PATTERN.FindStringSubmatch(word.prefix)
+		if match == nil {
+			kept = append(kept, word)
+			continue
+		}
+
+		// the file is named in the same word, e.g >out.txt, or by the next
+		target := word
+		if word.literal && len(word.prefix) == len(match[0]) && idx+1 < len(words) {
+			idx++
+			target = words[idx]
+		}
+
+		if target.input != "" && strings.Contains(match[1], ">") {
+			reason := target.input + " names a file that is written to by '" + match[0] + "'"
+			findings = append(findings, AuditFinding{target.line, target.col, reason})
+		}
+	}
+
+	return kept, findings
+}
+
+// Check a template for fish, nushell, or PowerShell for dangerous uses of user-input. These shells can't
+// be parsed fully, so the template is split into commands and words, and checked for user-input:
+// - used as a command-name, including behind wrappers like sudo, env, or xargs
+// - evaluated as code; by eval, source, or Invoke-Expression, a shell's -c flag, or piped into a shell
+// - naming a file that is written to by a redirection
+// - as an argument to destructive commands like rm or dd
+//
+// These shells don't split variables into words, so unquoted variables are fine.
+func AnalyseWords(template string, profile ShellProfile, names InputNames) []AuditFinding {
+	powershell := isPowerShell(profile.name)
+
+	vars := []string{}
+	for name := range names {
+		vars = append(vars, profile.Var(name))
+	}
+
+	findings := []AuditFinding{}
+
+	for _, command := range ScanWords(template, vars, powershell) {
+		words := command.words
+
+		for len(words) > 0 && words[0].literal && COMMAND_KEYWORDS[strings.ToLower(words[0].text)] {
+			words = words[1:]
+		}
+
+		words, redirectFindings := auditRedirects(words)
+		findings = append(findings, redirectFindings...)
+
+		if len(words) == 0 {
+			continue
+		}
+
+		// a variable starting a command is a value in some shells, e.g $env.RL_INPUT | str length in nushell
+		if EXPRESSION_SHELLS[profile.name] && words[0].input != "" && words[0].prefix == "" {
+			continue
+		}
+
+		audited := make([]AuditWord, len(words))
+		for idx, word := range words {
+			audited[idx] = word.AuditWord
+
+			if powershell {
+				audited[idx].text = strings.ToLower(word.text)
+			}
+		}
+
+		for _, found := range names.auditWords(audited) {
+			word := words[found.word]
+			findings = append(findings, AuditFinding{word.line, word.col, found.reason})
+		}
+
+		if command.piped == nil {
+			continue
+		}
+
+		// user-input piped into a shell, or into a command evaluating its standard-input, is run as code
+		nameIdx := commandWord(audited)
+		if nameIdx == -1 || audited[nameIdx].input != "" {
+			continue
+		}
+
+		name := filepath.Base(audited[nameIdx].text)
+		rest := audited[nameIdx+1:]
+
+		if EVALUATING_SHELLS[name] && readsStdinScript(name, rest) || EVALUATING_COMMANDS[name] && len(rest) == 0 {
+			reason := command.piped.input + " is piped into '" + name + "', which runs it as code"
+			findings = append(findings, AuditFinding{command.piped.line, command.piped.col, reason})
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAnalyseWords(t *testing.T) {
+	cases := []struct {
+		shell    string
+		template string
+		reason   string // part of the reason for one of the findings; empty when the template is safe
+	}{
+		// safe templates
+		{"fish", `grep $RL_INPUT file`, ""},
+		{"fish", `grep -- "$RL_INPUT" file | head -n 5`, ""},
+		{"fish", `grep '$RL_INPUT' file`, ""},
+		{"fish", `echo $RL_INPUT_X; rm -f /tmp/cache`, ""},
+		{"fish", `sudo grep $RL_INPUT /var/log/syslog`, ""},
+		{"fish", `grep $RL_INPUT file > out.txt 2>&1`, ""},
+		{"fish", `if test -n "$RL_INPUT"; grep $RL_INPUT file; end`, ""},
+		{"nu", `open data.json | where name =~ $env.RL_INPUT`, ""},
+		{"nu", `$env.RL_INPUT | str length`, ""},
+		{"pwsh", `Select-String -Pattern $env:RL_INPUT -Path *.log`, ""},
+		{"pwsh", `Get-ChildItem | Where-Object { $_.Name -like "*$env:RL_INPUT*" }`, ""},
+
+		// used as a command-name
+		{"fish", `$RL_INPUT --help`, "$RL_INPUT is used as a command-name"},
+		{"fish", `echo hi; and $RL_INPUT`, "$RL_INPUT is used as a command-name"},
+		{"fish", `sudo $RL_INPUT`, "$RL_INPUT is used as a command-name"},
+		{"fish", `env FOO=bar $RL_INPUT`, "$RL_INPUT is used as a command-name"},
+		{"nu", `^$env.RL_INPUT`, "$env.RL_INPUT is used as a command-name"},
+		{"nu", `run-external $env.RL_INPUT`, "$env.RL_INPUT is used as a command-name"},
+		{"pwsh", `& $env:RL_INPUT`, "$env:RL_INPUT is used as a command-name"},
+		{"pwsh", `& $ENV:rl_input`, "$env:RL_INPUT is used as a command-name"},
+
+		// evaluated as code
+		{"fish", `eval $RL_INPUT`, "$RL_INPUT is evaluated as code by 'eval'"},
+		{"fish", `fish -c $RL_INPUT`, "$RL_INPUT is evaluated as code by 'fish -c'"},
+		{"fish", `fish -c 'eval $RL_INPUT'`, "in the script run by 'fish -c', 1:6: $RL_INPUT is evaluated as code by 'eval'"},
+		{"fish", `bash -c 'eval "$RL_INPUT"'`, "in the script run by 'bash -c'"},
+		{"fish", `echo $RL_INPUT | sh`, "$RL_INPUT is piped into 'sh'"},
+		{"fish", `echo $RL_INPUT | source`, "$RL_INPUT is piped into 'source'"},
+		{"fish", `grep (eval $RL_INPUT) file`, "evaluated as code by 'eval'"},
+		{"nu", `nu -c $env.RL_INPUT`, "$env.RL_INPUT is evaluated as code by 'nu -c'"},
+		{"pwsh", `Invoke-Expression $env:RL_INPUT`, "$env:RL_INPUT is evaluated as code by 'invoke-expression'"},
+		{"pwsh", `$env:RL_INPUT | iex`, "$env:RL_INPUT is piped into 'iex'"},
+
+		// writes to a file
+		{"fish", `echo hello > $RL_INPUT`, "names a file that is written to by '>'"},
+		{"fish", `echo hello >>$RL_INPUT`, "names a file that is written to by '>>'"},
+		{"nu", `echo hello o> $env.RL_INPUT`, "names a file that is written to by 'o>'"},
+
+		// destructive commands
+		{"fish", `rm -rf $RL_INPUT`, "an argument to 'rm'"},
+		{"fish", `rm (string trim $RL_INPUT)`, "an argument to 'rm'"},
+		{"fish", `nice -n 5 rm $RL_INPUT`, "an argument to 'rm'"},
+		{"pwsh", `Remove-Item -Recurse $env:RL_INPUT`, "an argument to 'remove-item'"},
+	}
+
+	for _, testCase := range cases {
+		findings := AnalyseWords(testCase.template, FindShellProfile(testCase.shell), NewInputNames(nil))
+
+		if testCase.reason == "" {
+			if len(findings) != 0 {
+				t.Errorf("%v %q: expected no findings, got %v", testCase.shell, testCase.template, findings)
+			}
+			continue
+		}
+
+		found := false
+		for _, finding := range findings {
+			found = found || strings.Contains(finding.String(), testCase.reason)
+		}
+
+		if !found {
+			t.Errorf("%v %q: expected a finding containing %q, got %v", testCase.shell, testCase.template, testCase.reason, findings)
+		}
+	}
+}
+
+func TestCheckAuditable(t *testing.T) {
+	for _, shell := range []string{"sh", "/bin/bash", "zsh", "dash", "fish", "/usr/bin/nu", "pwsh"} {
+		if err := CheckAuditable(FindShellProfile(shell)); err != nil {
+			t.Errorf("%v: expected it to be auditable, got %v", shell, err)
+		}
+	}
+
+	for _, shell := range []string{"tcsh", "elvish", "/usr/bin/xonsh"} {
+		if err := CheckAuditable(FindShellProfile(shell)); err == nil {
+			t.Errorf("%v: expected it not to be auditable", shell)
+		}
+	}
+}